@@ -0,0 +1,101 @@
+package analysis
+
+import (
+	"makeotel/parser"
+	"sort"
+	"time"
+)
+
+const (
+	StatusAdded     = "added"
+	StatusRemoved   = "removed"
+	StatusChanged   = "changed"
+	StatusUnchanged = "unchanged"
+)
+
+// Change compares a target in two profiles, which are matched by the
+// target's id.  A target only in the old profile was removed, and one only in
+// the new profile was added; its times in the other profile are zero.
+type Change struct {
+	ID     string
+	Name   string
+	Status string
+
+	OldSelf      time.Duration
+	NewSelf      time.Duration
+	OldInclusive time.Duration
+	NewInclusive time.Duration
+}
+
+func (c *Change) SelfDelta() time.Duration {
+	return c.NewSelf - c.OldSelf
+}
+
+func (c *Change) InclusiveDelta() time.Duration {
+	return c.NewInclusive - c.OldInclusive
+}
+
+// Diff compares every target in the two profiles, sorted by the size of
+// the change in their self time, as that points at the target which changed
+// rather than everything which depends on it.  Ties are sorted by the
+// change in inclusive time, and then by name and id.
+func Diff(before, after *parser.Profile) []*Change {
+	changes := map[string]*Change{}
+	order := []*Change{}
+
+	change := func(target *Target) *Change {
+		if c, found := changes[target.ID]; found {
+			return c
+		}
+
+		c := &Change{ID: target.ID, Name: target.Name}
+		changes[target.ID] = c
+		order = append(order, c)
+		return c
+	}
+
+	for _, target := range Targets(before) {
+		c := change(target)
+		c.OldSelf = target.Self
+		c.OldInclusive = target.Inclusive
+		c.Status = StatusRemoved
+	}
+
+	for _, target := range Targets(after) {
+		c := change(target)
+		c.NewSelf = target.Self
+		c.NewInclusive = target.Inclusive
+
+		switch {
+		case c.Status == "":
+			c.Status = StatusAdded
+		case c.SelfDelta() == 0 && c.InclusiveDelta() == 0:
+			c.Status = StatusUnchanged
+		default:
+			c.Status = StatusChanged
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if abs(a.SelfDelta()) != abs(b.SelfDelta()) {
+			return abs(a.SelfDelta()) > abs(b.SelfDelta())
+		}
+		if abs(a.InclusiveDelta()) != abs(b.InclusiveDelta()) {
+			return abs(a.InclusiveDelta()) > abs(b.InclusiveDelta())
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+
+	return order
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
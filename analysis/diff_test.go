@@ -0,0 +1,56 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	changes := Diff(
+		loadProfile(t, "../example/callgrind.out.build-1"),
+		loadProfile(t, "../example/callgrind.out.build-3"),
+	)
+
+	names := []string{}
+	for _, change := range changes {
+		names = append(names, change.Name)
+	}
+
+	// largest change in self time first, then inclusive time
+	assert.Equal(t, []string{"three.js", "two.js", "one.js", "build", "three.ts", "two.ts", "one.ts"}, names)
+
+	three := changes[0]
+	assert.Equal(t, StatusAdded, three.Status)
+	assert.Equal(t, time.Duration(0), three.OldSelf)
+	assert.Equal(t, 5003300*time.Microsecond, three.SelfDelta())
+
+	one := changes[2]
+	assert.Equal(t, StatusChanged, one.Status)
+	assert.Equal(t, -200*time.Microsecond, one.SelfDelta())
+
+	build := changes[3]
+	assert.Equal(t, -100*time.Microsecond, build.SelfDelta())
+	assert.Equal(t, 6006600*time.Microsecond, build.InclusiveDelta())
+
+	assert.Equal(t, StatusUnchanged, changes[6].Status)
+}
+
+func TestDiffRemoved(t *testing.T) {
+	changes := Diff(
+		loadProfile(t, "../example/callgrind.out.build-2"),
+		loadProfile(t, "../example/callgrind.out.build-1"),
+	)
+
+	var two *Change
+	for _, change := range changes {
+		if change.Name == "two.js" {
+			two = change
+		}
+	}
+
+	assert.Equal(t, StatusRemoved, two.Status)
+	assert.Equal(t, time.Duration(0), two.NewInclusive)
+	assert.Equal(t, -1002200*time.Microsecond, two.InclusiveDelta())
+}
@@ -0,0 +1,179 @@
+package analysis
+
+import (
+	"makeotel/parser"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	TimeSelf      = "self"
+	TimeInclusive = "inclusive"
+)
+
+var TimeColumns = []string{TimeSelf, TimeInclusive}
+
+// Stats is the distribution of a target's time across many profiles, which
+// are matched by the target's id.
+type Stats struct {
+	ID   string
+	Name string
+
+	// Count is how many of the profiles have the target
+	Count int
+
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+
+	// Trend is how much the time changes from one profile to the next, from
+	// a least squares fit, so a target getting slower has a positive trend
+	Trend time.Duration
+
+	// Times has the target's time in each profile it is in, in the order of
+	// the profiles
+	Times []time.Duration
+}
+
+// Summarise gives the stats of every target in the profiles, which should
+// be in the order the builds ran for the trend to make sense.  column is
+// which time to use, one of TimeColumns.
+func Summarise(profiles []*parser.Profile, column string) []*Stats {
+	byID := map[string]*Stats{}
+	order := []*Stats{}
+	runs := map[string][]float64{}
+
+	for i, profile := range profiles {
+		for _, target := range Targets(profile) {
+			stats, found := byID[target.ID]
+			if !found {
+				stats = &Stats{ID: target.ID, Name: target.Name}
+				byID[target.ID] = stats
+				order = append(order, stats)
+			}
+
+			value := target.Self
+			if column == TimeInclusive {
+				value = target.Inclusive
+			}
+
+			stats.Times = append(stats.Times, value)
+			runs[target.ID] = append(runs[target.ID], float64(i))
+		}
+	}
+
+	for _, stats := range order {
+		stats.summarise(runs[stats.ID])
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].ID < order[j].ID
+	})
+
+	return order
+}
+
+func (s *Stats) summarise(runs []float64) {
+	sorted := make([]time.Duration, len(s.Times))
+	copy(sorted, s.Times)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	total := time.Duration(0)
+	for _, t := range sorted {
+		total += t
+	}
+
+	s.Count = len(sorted)
+	s.Mean = total / time.Duration(s.Count)
+	s.P50 = percentile(sorted, 50)
+	s.P90 = percentile(sorted, 90)
+	s.P99 = percentile(sorted, 99)
+	s.Max = sorted[len(sorted)-1]
+	s.Trend = trend(runs, s.Times)
+}
+
+// percentile uses the nearest rank, so it is always one of the times.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// trend is the slope of the least squares line through the times, with the
+// profile's position as x, so a target missing from some profiles is still
+// measured against when it ran.
+func trend(runs []float64, times []time.Duration) time.Duration {
+	n := float64(len(runs))
+	if n < 2 {
+		return 0
+	}
+
+	meanX, meanY := 0.0, 0.0
+	for i := range runs {
+		meanX += runs[i]
+		meanY += float64(times[i])
+	}
+	meanX /= n
+	meanY /= n
+
+	covariance, variance := 0.0, 0.0
+	for i := range runs {
+		covariance += (runs[i] - meanX) * (float64(times[i]) - meanY)
+		variance += (runs[i] - meanX) * (runs[i] - meanX)
+	}
+
+	return time.Duration(covariance / variance)
+}
+
+const (
+	StatCount = "count"
+	StatMean  = "mean"
+	StatP50   = "p50"
+	StatP90   = "p90"
+	StatP99   = "p99"
+	StatMax   = "max"
+	StatTrend = "trend"
+)
+
+var StatColumns = []string{SortName, StatCount, StatMean, StatP50, StatP90, StatP99, StatMax, StatTrend}
+
+// SortStats orders the stats by a column, one of StatColumns; names sort
+// alphabetically, and everything else sorts largest first.
+func SortStats(stats []*Stats, column string) {
+	value := func(s *Stats) float64 {
+		switch column {
+		case StatCount:
+			return float64(s.Count)
+		case StatMean:
+			return float64(s.Mean)
+		case StatP50:
+			return float64(s.P50)
+		case StatP90:
+			return float64(s.P90)
+		case StatP99:
+			return float64(s.P99)
+		case StatMax:
+			return float64(s.Max)
+		case StatTrend:
+			return float64(s.Trend)
+		default:
+			return 0
+		}
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if value(a) != value(b) {
+			return value(a) > value(b)
+		}
+		return strings.Compare(a.Name, b.Name) < 0
+	})
+}
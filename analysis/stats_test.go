@@ -0,0 +1,63 @@
+package analysis
+
+import (
+	"makeotel/parser"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func findStats(stats []*Stats, name string) *Stats {
+	for _, s := range stats {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func TestSummarise(t *testing.T) {
+	profiles := []*parser.Profile{
+		loadProfile(t, "../example/callgrind.out.build-1"),
+		loadProfile(t, "../example/callgrind.out.build-2"),
+		loadProfile(t, "../example/callgrind.out.build-3"),
+	}
+
+	stats := Summarise(profiles, TimeInclusive)
+	assert.Len(t, stats, 7)
+
+	build := findStats(stats, "build")
+	assert.Equal(t, 3, build.Count)
+	assert.Equal(t, []time.Duration{3002900 * time.Microsecond, 4004200 * time.Microsecond, 9009500 * time.Microsecond}, build.Times)
+	assert.Equal(t, 4004200*time.Microsecond, build.P50)
+	assert.Equal(t, 9009500*time.Microsecond, build.P90)
+	assert.Equal(t, 9009500*time.Microsecond, build.Max)
+	assert.Equal(t, 5338866666*time.Nanosecond, build.Mean)
+	assert.Equal(t, 3003300*time.Microsecond, build.Trend)
+
+	// two.js is only in the last two builds
+	two := findStats(stats, "two.js")
+	assert.Equal(t, 2, two.Count)
+	assert.Equal(t, 6006900*time.Microsecond-1002200*time.Microsecond, two.Trend)
+
+	three := findStats(stats, "three.js")
+	assert.Equal(t, 1, three.Count)
+	assert.Equal(t, time.Duration(0), three.Trend)
+}
+
+func TestSortStats(t *testing.T) {
+	stats := []*Stats{
+		{Name: "a", P90: time.Second, Trend: -time.Second},
+		{Name: "b", P90: 2 * time.Second},
+		{Name: "c", P90: time.Second, Trend: time.Second},
+	}
+
+	SortStats(stats, StatP90)
+	assert.Equal(t, "b", stats[0].Name)
+	assert.Equal(t, "a", stats[1].Name)
+
+	SortStats(stats, StatTrend)
+	assert.Equal(t, "c", stats[0].Name)
+	assert.Equal(t, "a", stats[2].Name)
+}
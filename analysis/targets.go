@@ -0,0 +1,185 @@
+package analysis
+
+import (
+	"makeotel/parser"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Target summarises the time spent in one make target.
+type Target struct {
+	ID   string
+	Name string
+
+	// Self is the time spent in the target's own recipe, and Inclusive adds
+	// the time spent building its prerequisites.
+	Self      time.Duration
+	Inclusive time.Duration
+	Calls     int
+
+	// Percent is the Inclusive time as a percentage of the whole profile.
+	Percent float64
+}
+
+// Node is a target in the call tree, so a target which is a prerequisite of
+// several others appears once under each of them.
+type Node struct {
+	Target
+	Children []*Node
+}
+
+// Targets summarises every target in the profile.
+func Targets(profile *parser.Profile) []*Target {
+	incoming := map[string]time.Duration{}
+	for _, fn := range profile.Functions() {
+		for _, call := range fn.Calls() {
+			incoming[call.CalleeId] += call.Cost
+		}
+	}
+
+	roots := profile.Roots()
+
+	targets := []*Target{}
+	for _, fn := range profile.Functions() {
+		inclusive, found := incoming[fn.ID]
+		if !found {
+			inclusive = rootCost(profile, roots, fn)
+		}
+
+		targets = append(targets, newTarget(profile, fn, inclusive, fn.Called))
+	}
+
+	return targets
+}
+
+// Tree gives the call tree of the profile, starting from each root target.
+func Tree(profile *parser.Profile) []*Node {
+	roots := profile.Roots()
+
+	nodes := make([]*Node, 0, len(roots))
+	for _, root := range roots {
+		nodes = append(nodes, tree(profile, root, rootCost(profile, roots, root), root.Called, map[string]bool{}))
+	}
+
+	return nodes
+}
+
+func tree(profile *parser.Profile, fn *parser.Function, inclusive time.Duration, calls int, visiting map[string]bool) *Node {
+	node := &Node{
+		Target:   *newTarget(profile, fn, inclusive, calls),
+		Children: []*Node{},
+	}
+
+	// make refuses circular dependencies, but a corrupt profile could have them
+	visiting[fn.ID] = true
+	defer delete(visiting, fn.ID)
+
+	for _, call := range fn.Calls() {
+		callee, found := profile.GetFunction(call.CalleeId)
+		if !found || visiting[callee.ID] {
+			continue
+		}
+
+		node.Children = append(node.Children, tree(profile, callee, call.Cost, call.Calls, visiting))
+	}
+
+	return node
+}
+
+func newTarget(profile *parser.Profile, fn *parser.Function, inclusive time.Duration, calls int) *Target {
+	self := inclusive - fn.CallTime()
+	if self < 0 {
+		self = 0
+	}
+
+	percent := 0.0
+	if profile.TotalCost > 0 {
+		percent = float64(inclusive) / float64(profile.TotalCost) * 100
+	}
+
+	return &Target{
+		ID:        fn.ID,
+		Name:      fn.Name,
+		Self:      self,
+		Inclusive: inclusive,
+		Calls:     calls,
+		Percent:   percent,
+	}
+}
+
+// rootCost is the inclusive time of a target nothing depends on.  With a
+// single root, that's the whole profile; otherwise the best guess is the
+// larger of its own cost and the cost of its prerequisites.
+func rootCost(profile *parser.Profile, roots []*parser.Function, fn *parser.Function) time.Duration {
+	if len(roots) == 1 && roots[0] == fn && profile.TotalCost > 0 {
+		return profile.TotalCost
+	}
+
+	if calls := fn.CallTime(); calls > fn.Cost {
+		return calls
+	}
+
+	return fn.Cost
+}
+
+const (
+	SortName      = "name"
+	SortSelf      = "self"
+	SortInclusive = "inclusive"
+	SortCalls     = "calls"
+	SortPercent   = "percent"
+)
+
+var SortColumns = []string{SortName, SortSelf, SortInclusive, SortCalls, SortPercent}
+
+// Less gives the ordering for a column; names sort alphabetically, and
+// everything else sorts largest first.  Ties are broken by name, so the
+// output is stable.
+func Less(column string) func(a, b *Target) bool {
+	byName := func(a, b *Target) bool {
+		return strings.Compare(a.Name, b.Name) < 0
+	}
+
+	largest := func(value func(t *Target) float64) func(a, b *Target) bool {
+		return func(a, b *Target) bool {
+			if value(a) != value(b) {
+				return value(a) > value(b)
+			}
+			return byName(a, b)
+		}
+	}
+
+	switch column {
+	case SortSelf:
+		return largest(func(t *Target) float64 { return float64(t.Self) })
+	case SortInclusive:
+		return largest(func(t *Target) float64 { return float64(t.Inclusive) })
+	case SortCalls:
+		return largest(func(t *Target) float64 { return float64(t.Calls) })
+	case SortPercent:
+		return largest(func(t *Target) float64 { return t.Percent })
+	default:
+		return byName
+	}
+}
+
+// SortTargets orders the targets by a column, one of SortColumns.
+func SortTargets(targets []*Target, column string) {
+	less := Less(column)
+	sort.SliceStable(targets, func(i, j int) bool {
+		return less(targets[i], targets[j])
+	})
+}
+
+// SortTree orders each level of the tree by a column.
+func SortTree(nodes []*Node, column string) {
+	less := Less(column)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return less(&nodes[i].Target, &nodes[j].Target)
+	})
+
+	for _, node := range nodes {
+		SortTree(node.Children, column)
+	}
+}
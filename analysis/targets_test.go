@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"makeotel/parser"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadProfile(t *testing.T, path string) *parser.Profile {
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	profile, err := parser.NewCallgrindParser(file).Parse()
+	assert.NoError(t, err)
+
+	return profile
+}
+
+func findTarget(targets []*Target, name string) *Target {
+	for _, target := range targets {
+		if target.Name == name {
+			return target
+		}
+	}
+	return nil
+}
+
+func TestTargets(t *testing.T) {
+	targets := Targets(loadProfile(t, "../example/callgrind.out.build-3"))
+	assert.Len(t, targets, 7)
+
+	build := findTarget(targets, "build")
+	assert.Equal(t, 9009500*time.Microsecond, build.Inclusive)
+	assert.Equal(t, 0, build.Calls)
+	assert.Equal(t, 100.0, build.Percent)
+
+	two := findTarget(targets, "two.js")
+	assert.Equal(t, 6006900*time.Microsecond, two.Inclusive)
+	assert.Equal(t, 1003400*time.Microsecond, two.Self)
+	assert.Equal(t, 1, two.Calls)
+
+	one := findTarget(targets, "one.js")
+	assert.Equal(t, 3002200*time.Microsecond, one.Self)
+}
+
+func TestSortTargets(t *testing.T) {
+	targets := Targets(loadProfile(t, "../example/callgrind.out.build-3"))
+
+	SortTargets(targets, SortSelf)
+	assert.Equal(t, "three.js", targets[0].Name)
+	assert.Equal(t, "one.js", targets[1].Name)
+
+	SortTargets(targets, SortName)
+	assert.Equal(t, "build", targets[0].Name)
+	assert.Equal(t, "two.ts", targets[len(targets)-1].Name)
+}
+
+func TestTree(t *testing.T) {
+	nodes := Tree(loadProfile(t, "../example/callgrind.out.build-3"))
+	SortTree(nodes, SortInclusive)
+
+	assert.Len(t, nodes, 1)
+	assert.Equal(t, "build", nodes[0].Name)
+
+	names := []string{}
+	for _, child := range nodes[0].Children {
+		names = append(names, child.Name)
+	}
+	assert.Equal(t, []string{"two.js", "one.js"}, names)
+
+	three := nodes[0].Children[0].Children[0]
+	assert.Equal(t, "three.js", three.Name)
+	assert.Equal(t, "three.ts", three.Children[0].Name)
+}
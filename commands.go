@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"makeotel/tracing"
+	"makeotel/version"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const helpWidth = 110
+
+type flagGroup struct {
+	title string
+	flags *pflag.FlagSet
+}
+
+type command struct {
+	name        string
+	summary     string
+	usage       string
+	description string
+
+	// stopAtArgs means every argument after the first non-flag belongs to
+	// the command, such as the make command line for exec
+	stopAtArgs bool
+
+	// flags are bound to the configs, with their defaults taken from the
+	// settings
+	flags func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup
+	run   func(conf *config, otelConf *tracing.Config, args []string) error
+}
+
+func commands() []*command {
+	return []*command{
+		sendCommand(),
+		execCommand(),
+		watchCommand(),
+		inspectCommand(),
+		convertCommand(),
+		reportCommand(),
+		diffCommand(),
+		statsCommand(),
+		validateCommand(),
+		traceParentCommand(),
+		versionCommand(),
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// everyFlag has the flags from all commands, so the settings files can be
+// checked for unknown keys without each command rejecting the others' flags.
+func everyFlag() *pflag.FlagSet {
+	all := pflag.NewFlagSet("all", pflag.ContinueOnError)
+
+	for _, cmd := range commands() {
+		for _, group := range cmd.flags(settings{}, &config{}, &tracing.Config{}) {
+			group.flags.VisitAll(func(f *pflag.Flag) {
+				if all.Lookup(f.Name) == nil {
+					all.AddFlag(f)
+				}
+			})
+		}
+	}
+
+	return all
+}
+
+// flagSet has all of the command's flags, bound to the configs.
+func (c *command) flagSet(defaults settings, conf *config, otelConf *tracing.Config) *pflag.FlagSet {
+	flags := pflag.NewFlagSet(c.name, pflag.ContinueOnError)
+	for _, group := range c.flags(defaults, conf, otelConf) {
+		flags.AddFlagSet(group.flags)
+	}
+	flags.SetInterspersed(!c.stopAtArgs)
+
+	return flags
+}
+
+func (c *command) execute(args []string) error {
+	conf := &config{}
+	otelConf := &tracing.Config{}
+
+	flags := c.flagSet(fileSettings, conf, otelConf)
+
+	if err := fileSettings.validate(everyFlag()); err != nil {
+		return err
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if conf.version {
+		fmt.Println(version.VersionNumber())
+		return nil
+	}
+
+	if conf.help {
+		return c.helpText()
+	}
+
+	return c.run(conf, otelConf, flags.Args())
+}
+
+func (c *command) helpText() error {
+	sb := strings.Builder{}
+	sb.WriteString(c.description + "\n\nUsage:\n\n      " + c.usage + "\n")
+
+	// show the built in defaults, and never print credentials from the
+	// settings files
+	for _, group := range c.flags(settings{}, &config{}, &tracing.Config{}) {
+		sb.WriteString("\n" + group.title + ":\n\n" + group.flags.FlagUsagesWrapped(helpWidth))
+	}
+
+	fmt.Println(sb.String())
+	return nil
+}
+
+func helpText() error {
+	sb := strings.Builder{}
+	sb.WriteString(`Turns a callgrind format profile from Remake into an OpenTelemetry Trace, and
+send it to an OpenTelemetry collector.
+
+Usage:
+
+      makeotel <command> [flags] [arguments]
+
+Commands:
+
+`)
+
+	for _, cmd := range commands() {
+		sb.WriteString(fmt.Sprintf("      %-10s %s\n", cmd.name, cmd.summary))
+	}
+
+	sb.WriteString(`
+Run 'makeotel <command> --help' to see the flags for a command.`)
+
+	fmt.Println(sb.String())
+	return nil
+}
+
+func versionCommand() *command {
+	return &command{
+		name:        "version",
+		summary:     "Print the version of this tool",
+		usage:       "makeotel version",
+		description: "Prints the version of this tool.",
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			return []flagGroup{
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: func(conf *config, otelConf *tracing.Config, args []string) error {
+			fmt.Println(version.VersionNumber())
+			return nil
+		},
+	}
+}
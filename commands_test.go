@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"makeotel/version"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureStdout gives what f prints, as the help text goes straight to stdout.
+func captureStdout(t *testing.T, f func() error) (string, error) {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		content, _ := io.ReadAll(r)
+		output <- string(content)
+	}()
+
+	err = f()
+	w.Close()
+
+	return <-output, err
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(OtelTracesExporterEnvVar, "")
+	t.Cleanup(func() { fileSettings = settings{} })
+
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report")
+	profile := "example/callgrind.out.build-1"
+
+	sections := writeSettings(t, "exporter: none\nstats:\n  sort: p99\n")
+	typo := writeSettings(t, "exporter: none\nsettle-time: 1s\n")
+
+	cases := []struct {
+		name     string
+		args     []string
+		stdout   string
+		reported bool
+		err      string
+	}{
+		{name: "no args", args: []string{}, stdout: "Commands:"},
+		{name: "help", args: []string{"help"}, stdout: "Commands:"},
+		{name: "help flag", args: []string{"--help"}, stdout: "Commands:"},
+		{name: "help for a command", args: []string{"help", "diff"}, stdout: "makeotel diff [flags] <old_profile> <new_profile>"},
+		{name: "help for an unknown command", args: []string{"help", "frobnicate"}, stdout: "Commands:"},
+		{name: "command help flag", args: []string{"stats", "--help"}, stdout: "--order"},
+		{name: "version", args: []string{"version"}, stdout: version.VersionNumber()},
+		{name: "send", args: []string{"send", "--exporter", "none", "--output", report, profile}, reported: true},
+		{name: "legacy send", args: []string{"--exporter", "none", "--output", report, profile}, reported: true},
+		{name: "validate a quoted glob", args: []string{"validate", "example/callgrind.out.build-[12]"}, stdout: "example/callgrind.out.build-2: ok"},
+		{name: "flag from another command", args: []string{"inspect", "--exporter", "none", profile}, err: "unknown flag: --exporter"},
+		{name: "unknown command", args: []string{"frobnicate", profile}, err: `unknown command "frobnicate"`},
+		{name: "legacy send of a missing profile", args: []string{"--exporter", "none", "frobnicate"}, err: "1 of 1 profiles could not be sent"},
+		{name: "legacy send of a profile", args: []string{profile, "--exporter", "none", "--output", report}, reported: true},
+		{name: "settings for another command", args: []string{"send", "--config", sections, "--output", report, profile}, reported: true},
+		{name: "unknown setting", args: []string{"send", "--config", typo, "--output", report, profile}, err: "unknown settings in .makeotel.yaml: settle-time"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			os.Remove(report)
+
+			stdout, err := captureStdout(t, func() error { return run(c.args) })
+
+			if c.err != "" {
+				assert.ErrorContains(t, err, c.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, c.stdout)
+
+			if c.reported {
+				content, err := os.ReadFile(report)
+				assert.NoError(t, err)
+				assert.Contains(t, string(content), profile+": build")
+			}
+		})
+	}
+}
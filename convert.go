@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"makeotel/convert"
+	"makeotel/layout"
+	"makeotel/parser"
+	"makeotel/tracing"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type convertConfig struct {
+	to   string
+	path string
+}
+
+func convertFlags(defaults settings, conf *convertConfig) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("convert", pflag.ContinueOnError)
+	flags.StringVar(&conf.to, "to", defaults.String("to", ""), "the format to convert to: "+strings.Join(convert.Formats, ", "))
+	flags.StringVar(&conf.path, "output-file", defaults.String("output-file", "-"), "where to write the converted profile, - for stdout")
+
+	return flags
+}
+
+func convertCommand() *command {
+	convertConf := &convertConfig{}
+
+	return &command{
+		name:    "convert",
+		summary: "Convert profiles to another tool's format",
+		usage:   "makeotel convert --to <format> [flags] <path_to_profile>...",
+		description: `Converts profiles to another tool's format, laying out the targets in the same
+way as the spans which would be sent.
+
+  chrome-trace   trace-event JSON, for chrome://tracing or Perfetto
+  folded         folded stacks, one line per stack with its self time in
+                 microseconds, for flamegraph.pl and speedscope
+  flamegraph     an SVG flame graph, which can be zoomed by clicking a target.
+                 flamegraph.svg is also accepted
+  pprof          a gzipped profile.proto, for go tool pprof`,
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			input := inputFlags(defaults, conf)
+			input.MarkHidden("single-trace")
+
+			return []flagGroup{
+				{"Convert Flags", convertFlags(defaults, convertConf)},
+				{"Input Flags", input},
+				{"Trace Flags", layoutTraceFlags(defaults, conf)},
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: func(conf *config, otelConf *tracing.Config, args []string) error {
+			return runConvert(conf, convertConf, args)
+		},
+	}
+}
+
+func runConvert(conf *config, convertConf *convertConfig, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("convert takes at least one argument: path")
+	}
+
+	if format, found := convert.FormatAliases[convertConf.to]; found {
+		convertConf.to = format
+	}
+
+	if !contains(convert.Formats, convertConf.to) {
+		return fmt.Errorf("unknown format %q for --to, expected one of %s", convertConf.to, strings.Join(convert.Formats, ", "))
+	}
+
+	if err := parser.CheckFormat(conf.inputFormat); err != nil {
+		return err
+	}
+
+	paths, err := expandPaths(args)
+	if err != nil {
+		return err
+	}
+
+	files, failed := loadProfiles(paths, conf.inputFormat, time.Unix(conf.timestamp, 0))
+
+	if err := attachProfiles(profilesOf(files), conf.attach); err != nil {
+		return err
+	}
+
+	if len(files) > 0 {
+		w, closer, err := createOutput(convertConf.path)
+		if err != nil {
+			return err
+		}
+
+		err = writeConverted(w, conf, convertConf.to, files)
+		if closeErr := closer(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profiles could not be converted", failed, len(paths))
+	}
+
+	return nil
+}
+
+func writeConverted(w io.Writer, conf *config, to string, files []*profileFile) error {
+	switch to {
+	case convert.ChromeTrace:
+		traces := make([]convert.Trace, 0, len(files))
+		for _, file := range files {
+			traces = append(traces, convert.Trace{
+				Name: file.path,
+				Root: layout.Build(file.profile, file.start, conf.layoutOptions()),
+			})
+		}
+
+		return convert.WriteChromeTrace(w, traces)
+
+	case convert.Folded:
+		return convert.WriteFolded(w, profilesOf(files))
+
+	case convert.Flamegraph:
+		names := make([]string, 0, len(files))
+		for _, file := range files {
+			names = append(names, filepath.Base(file.path))
+		}
+
+		return convert.WriteFlamegraph(w, profilesOf(files), strings.Join(names, ", "))
+
+	case convert.Pprof:
+		return convert.WritePprof(w, profilesOf(files))
+	}
+
+	return nil
+}
+
+func profilesOf(files []*profileFile) []*parser.Profile {
+	profiles := make([]*parser.Profile, 0, len(files))
+	for _, file := range files {
+		profiles = append(profiles, file.profile)
+	}
+	return profiles
+}
+
+// createOutput opens the file to write to, or stdout for -.
+func createOutput(path string) (io.Writer, func() error, error) {
+	if path == "" || path == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, f.Close, nil
+}
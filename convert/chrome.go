@@ -0,0 +1,166 @@
+package convert
+
+import (
+	"encoding/json"
+	"io"
+	"makeotel/layout"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	ChromeTrace = "chrome-trace"
+	Folded      = "folded"
+	Flamegraph  = "flamegraph"
+	Pprof       = "pprof"
+)
+
+// Formats are the formats profiles can be converted to.
+var Formats = []string{ChromeTrace, Folded, Flamegraph, Pprof}
+
+// FormatAliases are other names which are accepted for the formats.
+var FormatAliases = map[string]string{
+	"flamegraph.svg": Flamegraph,
+}
+
+// Trace is one laid out profile, and the name to show it under.
+type Trace struct {
+	Name string
+	Root *layout.Span
+}
+
+type chromeEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Phase string                 `json:"ph"`
+	Ts    float64                `json:"ts"`
+	Dur   float64                `json:"dur,omitempty"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes the traces as trace-event JSON, which can be opened
+// in chrome://tracing or Perfetto.  Each trace is a process, and as the
+// viewers need the events on a thread to nest, spans which run in parallel
+// are put on threads of their own.
+func WriteChromeTrace(w io.Writer, traces []Trace) error {
+	events := []chromeEvent{}
+
+	for i, trace := range traces {
+		pid := i + 1
+		events = append(events, chromeEvent{
+			Name:  "process_name",
+			Phase: "M",
+			Pid:   pid,
+			Args:  map[string]interface{}{"name": trace.Name},
+		})
+
+		lanes := assignLanes(trace.Root)
+		for lane := range lanes.spans {
+			events = append(events, chromeEvent{
+				Name:  "thread_name",
+				Phase: "M",
+				Pid:   pid,
+				Tid:   lane + 1,
+				Args:  map[string]interface{}{"name": trace.Root.Name + laneSuffix(lane)},
+			})
+		}
+
+		trace.Root.Walk(func(span *layout.Span, depth int) {
+			event := chromeEvent{
+				Name:  span.Name,
+				Cat:   "target",
+				Phase: "X",
+				Ts:    microseconds(span.Start),
+				Dur:   float64(span.End.Sub(span.Start)) / float64(time.Microsecond),
+				Pid:   pid,
+				Tid:   lanes.of[span] + 1,
+				Args:  map[string]interface{}{},
+			}
+
+			if span.Body {
+				event.Cat = "body"
+			}
+
+			for _, kv := range span.Attributes {
+				event.Args[string(kv.Key)] = kv.Value.AsInterface()
+			}
+
+			events = append(events, event)
+		})
+	}
+
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
+
+func microseconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Microsecond)
+}
+
+func laneSuffix(lane int) string {
+	if lane == 0 {
+		return ""
+	}
+	return " (" + strconv.Itoa(lane+1) + ")"
+}
+
+type lanes struct {
+	spans [][]*layout.Span
+	of    map[*layout.Span]int
+}
+
+// assignLanes puts each span on the same lane as its parent when it can, and
+// otherwise on the first lane where it doesn't overlap anything but its own
+// ancestors.  The viewers nest spans on a lane by time, so a span sharing a
+// lane with one it merely ran alongside would be drawn as its child.
+func assignLanes(root *layout.Span) *lanes {
+	l := &lanes{of: map[*layout.Span]int{}}
+	l.place(root, 0, map[*layout.Span]bool{})
+	return l
+}
+
+func (l *lanes) place(span *layout.Span, preferred int, ancestors map[*layout.Span]bool) {
+	lane := preferred
+	if !l.fits(lane, span, ancestors) {
+		for lane = 0; lane == preferred || !l.fits(lane, span, ancestors); lane++ {
+		}
+	}
+
+	for len(l.spans) <= lane {
+		l.spans = append(l.spans, nil)
+	}
+
+	l.spans[lane] = append(l.spans[lane], span)
+	l.of[span] = lane
+
+	children := append([]*layout.Span{}, span.Children...)
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].Start.Before(children[j].Start)
+	})
+
+	ancestors[span] = true
+	for _, child := range children {
+		l.place(child, lane, ancestors)
+	}
+	delete(ancestors, span)
+}
+
+func (l *lanes) fits(lane int, span *layout.Span, ancestors map[*layout.Span]bool) bool {
+	if lane >= len(l.spans) {
+		return true
+	}
+
+	for _, existing := range l.spans[lane] {
+		overlaps := span.Start.Before(existing.End) && existing.Start.Before(span.End)
+		if overlaps && !ancestors[existing] {
+			return false
+		}
+	}
+
+	return true
+}
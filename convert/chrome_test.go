@@ -0,0 +1,117 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"makeotel/layout"
+	"makeotel/parser"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func span(name string, start, end int, children ...*layout.Span) *layout.Span {
+	epoch := time.Unix(1_600_000_000, 0)
+
+	return &layout.Span{
+		Name:     name,
+		Start:    epoch.Add(time.Duration(start) * time.Second),
+		End:      epoch.Add(time.Duration(end) * time.Second),
+		Children: children,
+	}
+}
+
+func TestAssignLanes(t *testing.T) {
+	a := span("a", 0, 4)
+	b := span("b", 1, 3)
+	c := span("c", 2, 5)
+	body := span("body", 5, 6)
+	root := span("build", 0, 6, a, b, c, body)
+
+	lanes := assignLanes(root)
+
+	// b is inside a's time, but isn't its child, so it can't share its lane
+	assert.Equal(t, 0, lanes.of[root])
+	assert.Equal(t, 0, lanes.of[a])
+	assert.Equal(t, 1, lanes.of[b])
+	assert.Equal(t, 2, lanes.of[c])
+	assert.Equal(t, 0, lanes.of[body])
+}
+
+func TestAssignLanesParallel(t *testing.T) {
+	// ninja writes each command as it finishes, so a.o comes last
+	log := "# ninja log v5\n" +
+		"2000\t5000\t0\tb.o\t2\n" +
+		"5000\t6000\t0\tc.o\t3\n" +
+		"0\t10000\t0\ta.o\t1\n"
+
+	profile, err := parser.NewNinjaParser(strings.NewReader(log)).Parse()
+	assert.NoError(t, err)
+
+	root := layout.Build(profile, time.Unix(1_600_000_000, 0), layout.Options{})
+	lanes := assignLanes(root)
+
+	// a span may only overlap its own ancestors on a lane, as the viewers
+	// would draw anything else it overlaps as its parent
+	ancestors := map[*layout.Span][]*layout.Span{}
+	root.Walk(func(span *layout.Span, depth int) {
+		for _, child := range span.Children {
+			ancestors[child] = append(append([]*layout.Span{}, ancestors[span]...), span)
+		}
+	})
+
+	for _, spans := range lanes.spans {
+		for i, x := range spans {
+			for _, y := range spans[i+1:] {
+				if x.Start.Before(y.End) && y.Start.Before(x.End) {
+					related := containsSpan(ancestors[x], y) || containsSpan(ancestors[y], x)
+					assert.True(t, related, "%s and %s overlap on the same lane", x.Name, y.Name)
+				}
+			}
+		}
+	}
+
+	a, b, c := root.Children[0], root.Children[1], root.Children[2]
+	assert.Equal(t, "a.o", a.Name)
+	assert.NotEqual(t, lanes.of[a], lanes.of[b])
+	assert.NotEqual(t, lanes.of[a], lanes.of[c])
+	assert.Equal(t, lanes.of[b], lanes.of[c])
+}
+
+func containsSpan(spans []*layout.Span, span *layout.Span) bool {
+	for _, s := range spans {
+		if s == span {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWriteChromeTrace(t *testing.T) {
+	root := span("build", 0, 3, span("one.js", 0, 2), span("two.js", 1, 3))
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, WriteChromeTrace(out, []Trace{{Name: "callgrind.out.1", Root: root}}))
+
+	trace := struct {
+		TraceEvents []chromeEvent `json:"traceEvents"`
+	}{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &trace))
+
+	names := []string{}
+	for _, event := range trace.TraceEvents {
+		if event.Phase == "M" {
+			names = append(names, event.Args["name"].(string))
+		}
+	}
+	assert.Equal(t, []string{"callgrind.out.1", "build", "build (2)"}, names)
+
+	two := trace.TraceEvents[len(trace.TraceEvents)-1]
+	assert.Equal(t, "two.js", two.Name)
+	assert.Equal(t, "X", two.Phase)
+	assert.Equal(t, 2, two.Tid)
+	assert.Equal(t, float64(1_600_000_001_000_000), two.Ts)
+	assert.Equal(t, float64(2_000_000), two.Dur)
+}
@@ -0,0 +1,269 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"makeotel/analysis"
+	"makeotel/parser"
+	"sort"
+	"strings"
+	"time"
+)
+
+// frame is a stack in the flame graph; the same stack reached through
+// different paths in the call graph is merged, as flamegraph.pl would.
+type frame struct {
+	name     string
+	self     time.Duration
+	total    time.Duration
+	children map[string]*frame
+}
+
+func newFrame(name string) *frame {
+	return &frame{name: name, children: map[string]*frame{}}
+}
+
+func (f *frame) child(name string) *frame {
+	if c, found := f.children[name]; found {
+		return c
+	}
+
+	c := newFrame(name)
+	f.children[name] = c
+	return c
+}
+
+// sortedChildren orders the children by name, so the graph doesn't change
+// between runs.
+func (f *frame) sortedChildren() []*frame {
+	children := make([]*frame, 0, len(f.children))
+	for _, c := range f.children {
+		children = append(children, c)
+	}
+
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].name < children[j].name
+	})
+
+	return children
+}
+
+// stacks merges the call trees of the profiles, with each target's self time
+// as the weight of its stack.
+func stacks(profiles []*parser.Profile) *frame {
+	root := newFrame("all")
+
+	var add func(parent *frame, node *analysis.Node)
+	add = func(parent *frame, node *analysis.Node) {
+		f := parent.child(node.Name)
+		f.self += node.Self
+
+		for _, child := range node.Children {
+			add(f, child)
+		}
+	}
+
+	for _, profile := range profiles {
+		for _, node := range analysis.Tree(profile) {
+			add(root, node)
+		}
+	}
+
+	root.sum()
+
+	return root
+}
+
+func (f *frame) sum() time.Duration {
+	f.total = f.self
+	for _, c := range f.children {
+		f.total += c.sum()
+	}
+	return f.total
+}
+
+// WriteFolded writes Brendan Gregg's folded stack format: a line for each
+// stack, with the frames separated by semicolons, and its self time in
+// microseconds.
+func WriteFolded(w io.Writer, profiles []*parser.Profile) error {
+	out := bufio.NewWriter(w)
+
+	var write func(f *frame, stack []string)
+	write = func(f *frame, stack []string) {
+		// a semicolon would split the frame in two
+		stack = append(stack, strings.ReplaceAll(f.name, ";", ":"))
+
+		if us := f.self.Microseconds(); us > 0 {
+			fmt.Fprintf(out, "%s %d\n", strings.Join(stack, ";"), us)
+		}
+
+		for _, c := range f.sortedChildren() {
+			write(c, stack)
+		}
+	}
+
+	for _, f := range stacks(profiles).sortedChildren() {
+		write(f, nil)
+	}
+
+	return out.Flush()
+}
+
+const (
+	flameWidth       = 1200
+	flameFrameHeight = 16
+	flamePadding     = 10
+	flameTitleHeight = 40
+	flameCharWidth   = 7
+)
+
+// WriteFlamegraph renders the stacks as an SVG flame graph.  Each frame has a
+// tooltip with its times, and clicking a frame zooms in to it; the script to
+// do so is inside the SVG, so it needs nothing else to be viewed.
+func WriteFlamegraph(w io.Writer, profiles []*parser.Profile, title string) error {
+	root := stacks(profiles)
+
+	depth := root.depth()
+	height := flameTitleHeight + depth*flameFrameHeight + flamePadding*2
+	graphWidth := float64(flameWidth - flamePadding*2)
+
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, `<?xml version="1.0" standalone="no"?>
+<svg version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg" onload="init(evt)">
+<style>
+text { font-family: monospace; font-size: 12px; fill: #000; }
+.frame:hover rect { stroke: #000; stroke-width: 0.5; cursor: pointer; }
+#title { font-size: 17px; text-anchor: middle; }
+#reset { cursor: pointer; opacity: 0; }
+</style>
+<script><![CDATA[%s]]></script>
+<rect x="0" y="0" width="%d" height="%d" fill="#f8f8f8"/>
+<text id="title" x="%d" y="24">%s</text>
+<text id="reset" x="%d" y="24" onclick="reset()">Reset Zoom</text>
+`, flameWidth, height, flameWidth, height, flamegraphScript, flameWidth, height, flameWidth/2, html.EscapeString(title), flamePadding)
+
+	var draw func(f *frame, x float64, level int)
+	draw = func(f *frame, x float64, level int) {
+		width := 0.0
+		if root.total > 0 {
+			width = float64(f.total) / float64(root.total) * graphWidth
+		}
+
+		// anything narrower than a pixel can't be seen, or clicked on
+		if width < 1 && level > 0 {
+			return
+		}
+
+		y := height - flamePadding - (level+1)*flameFrameHeight
+		percent := 100.0
+		if root.total > 0 {
+			percent = float64(f.total) / float64(root.total) * 100
+		}
+
+		tooltip := fmt.Sprintf("%s (%s, %.2f%%, self %s)", f.name, f.total, percent, f.self)
+
+		fmt.Fprintf(out, `<g class="frame" data-name="%s" data-x="%.2f" data-w="%.2f" data-level="%d" onclick="zoom(this)">`+
+			`<title>%s</title>`+
+			`<rect x="%.2f" y="%d" width="%.2f" height="%d" rx="2" fill="%s"/>`+
+			`<text x="%.2f" y="%d">%s</text></g>`+"\n",
+			html.EscapeString(f.name), x, width, level,
+			html.EscapeString(tooltip),
+			x, y, width, flameFrameHeight-1, frameColour(f.name),
+			x+3, y+flameFrameHeight-4, html.EscapeString(fitText(f.name, width)))
+
+		childX := x
+		for _, c := range f.sortedChildren() {
+			draw(c, childX, level+1)
+			if root.total > 0 {
+				childX += float64(c.total) / float64(root.total) * graphWidth
+			}
+		}
+	}
+
+	draw(root, flamePadding, 0)
+
+	fmt.Fprintln(out, "</svg>")
+
+	return out.Flush()
+}
+
+func (f *frame) depth() int {
+	deepest := 0
+	for _, c := range f.children {
+		if d := c.depth(); d > deepest {
+			deepest = d
+		}
+	}
+	return deepest + 1
+}
+
+// fitText truncates the name to fit in the frame's width.
+func fitText(name string, width float64) string {
+	chars := int((width - 6) / flameCharWidth)
+	if chars >= len(name) {
+		return name
+	}
+
+	if chars < 3 {
+		return ""
+	}
+
+	return name[:chars-2] + ".."
+}
+
+// frameColour picks a warm colour from the name, so a target has the same
+// colour wherever it appears.
+func frameColour(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	v := h.Sum32()
+
+	r := 205 + v%50
+	g := 80 + (v>>8)%150
+	b := (v >> 16) % 55
+
+	return fmt.Sprintf("rgb(%d,%d,%d)", r, g, b)
+}
+
+const flamegraphScript = `
+var frames, resetText, fullWidth;
+function init(evt) {
+	frames = document.querySelectorAll(".frame");
+	resetText = document.getElementById("reset");
+	fullWidth = parseFloat(frames[0].getAttribute("data-w"));
+}
+function attr(g, name) { return parseFloat(g.getAttribute("data-" + name)); }
+function place(g, x, w) {
+	var rect = g.querySelector("rect"), text = g.querySelector("text");
+	var name = g.getAttribute("data-name");
+	g.style.display = w < 1 ? "none" : "";
+	rect.setAttribute("x", x);
+	rect.setAttribute("width", w);
+	text.setAttribute("x", x + 3);
+	var chars = Math.floor((w - 6) / 7);
+	text.textContent = chars >= name.length ? name : (chars < 3 ? "" : name.substring(0, chars - 2) + "..");
+}
+function zoom(target) {
+	var x = attr(target, "x"), w = attr(target, "w"), level = attr(target, "level"), left = attr(frames[0], "x");
+	var scale = fullWidth / w;
+	frames.forEach(function (g) {
+		var gx = attr(g, "x"), gw = attr(g, "w");
+		if (attr(g, "level") < level) {
+			place(g, left, gx <= x + 0.01 && gx + gw >= x + w - 0.01 ? fullWidth : 0);
+		} else if (gx >= x - 0.01 && gx + gw <= x + w + 0.01) {
+			place(g, left + (gx - x) * scale, gw * scale);
+		} else {
+			place(g, 0, 0);
+		}
+	});
+	resetText.style.opacity = 1;
+}
+function reset() {
+	frames.forEach(function (g) { place(g, attr(g, "x"), attr(g, "w")); });
+	resetText.style.opacity = 0;
+}
+`
@@ -0,0 +1,88 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"makeotel/parser"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadProfile(t *testing.T, path string) *parser.Profile {
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	p, err := parser.NewParser(f, parser.FormatAuto)
+	assert.NoError(t, err)
+
+	profile, err := p.Parse()
+	assert.NoError(t, err)
+
+	return profile
+}
+
+func TestWriteFolded(t *testing.T) {
+	out := &bytes.Buffer{}
+	assert.NoError(t, WriteFolded(out, []*parser.Profile{loadProfile(t, "../example/callgrind.out.build-3")}))
+
+	assert.Equal(t, `build 300
+build;one.js 3002200
+build;one.js;one.ts 100
+build;two.js 1003400
+build;two.js;three.js 5003300
+build;two.js;three.js;three.ts 100
+build;two.js;two.ts 100
+`, out.String())
+}
+
+func TestWriteFoldedMergesStacks(t *testing.T) {
+	// the two builds have the same targets, so their stacks are added up
+	profile := loadProfile(t, "../example/callgrind.out.build-2")
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, WriteFolded(out, []*parser.Profile{profile, profile}))
+
+	single := &bytes.Buffer{}
+	assert.NoError(t, WriteFolded(single, []*parser.Profile{profile}))
+
+	assert.Equal(t, len(strings.Split(single.String(), "\n")), len(strings.Split(out.String(), "\n")))
+	assert.Contains(t, out.String(), "build;one.js 6003")
+}
+
+func TestWriteFlamegraph(t *testing.T) {
+	out := &bytes.Buffer{}
+	assert.NoError(t, WriteFlamegraph(out, []*parser.Profile{loadProfile(t, "../example/callgrind.out.build-3")}, "<build>"))
+
+	// the names are escaped, so it's still valid xml
+	decoder := xml.NewDecoder(out)
+	frames := []string{}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "g" {
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "data-name" {
+					frames = append(frames, attr.Value)
+				}
+			}
+		}
+	}
+
+	// the .ts targets are too narrow to draw
+	assert.Equal(t, []string{"all", "build", "one.js", "two.js", "three.js"}, frames)
+}
+
+func TestFitText(t *testing.T) {
+	assert.Equal(t, "three.js", fitText("three.js", 100))
+	assert.Equal(t, "th..", fitText("three.js", 40))
+	assert.Equal(t, "", fitText("three.js", 10))
+}
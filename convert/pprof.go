@@ -0,0 +1,236 @@
+package convert
+
+import (
+	"compress/gzip"
+	"io"
+	"makeotel/analysis"
+	"makeotel/parser"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// the field numbers from pprof's profile.proto
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileMapping           = 3
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileDurationNanos     = 10
+	profileComment           = 13
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	mappingID           = 1
+	mappingFilename     = 5
+	mappingHasFunctions = 7
+
+	locationID        = 1
+	locationMappingID = 2
+	locationLine      = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// pprofBuilder collects the strings, functions and locations of a pprof
+// profile, which the samples refer to by index and id.
+type pprofBuilder struct {
+	strings     []string
+	stringIndex map[string]int64
+
+	functions []byte
+	locations []byte
+	samples   []byte
+
+	// locationIDs has a location for each target id, with its only line
+	// being the target's function
+	locationIDs map[string]uint64
+}
+
+func newPprofBuilder() *pprofBuilder {
+	return &pprofBuilder{
+		strings:     []string{""},
+		stringIndex: map[string]int64{"": 0},
+		locationIDs: map[string]uint64{},
+	}
+}
+
+func (b *pprofBuilder) str(value string) int64 {
+	if index, found := b.stringIndex[value]; found {
+		return index
+	}
+
+	index := int64(len(b.strings))
+	b.strings = append(b.strings, value)
+	b.stringIndex[value] = index
+	return index
+}
+
+func (b *pprofBuilder) location(fn *parser.Function) uint64 {
+	if id, found := b.locationIDs[fn.ID]; found {
+		return id
+	}
+
+	// each target is both a function and a location, so they share ids
+	id := uint64(len(b.locationIDs) + 1)
+	b.locationIDs[fn.ID] = id
+
+	function := []byte{}
+	function = appendVarint(function, functionID, id)
+	function = appendVarint(function, functionName, uint64(b.str(fn.Name)))
+	function = appendVarint(function, functionSystemName, uint64(b.str(fn.ID)))
+	function = appendVarint(function, functionFilename, uint64(b.str(fn.File)))
+	function = appendVarint(function, functionStartLine, uint64(fn.LineNumber))
+	b.functions = protowire.AppendTag(b.functions, profileFunction, protowire.BytesType)
+	b.functions = protowire.AppendBytes(b.functions, function)
+
+	line := []byte{}
+	line = appendVarint(line, lineFunctionID, id)
+	line = appendVarint(line, lineLine, uint64(fn.LineNumber))
+
+	location := []byte{}
+	location = appendVarint(location, locationID, id)
+	location = appendVarint(location, locationMappingID, 1)
+	location = protowire.AppendTag(location, locationLine, protowire.BytesType)
+	location = protowire.AppendBytes(location, line)
+	b.locations = protowire.AppendTag(b.locations, profileLocation, protowire.BytesType)
+	b.locations = protowire.AppendBytes(b.locations, location)
+
+	return id
+}
+
+// sample adds a stack, which pprof wants with the leaf first.
+func (b *pprofBuilder) sample(stack []uint64, values ...int64) {
+	locations := []byte{}
+	for i := len(stack) - 1; i >= 0; i-- {
+		locations = protowire.AppendVarint(locations, stack[i])
+	}
+
+	packed := []byte{}
+	for _, value := range values {
+		packed = protowire.AppendVarint(packed, uint64(value))
+	}
+
+	sample := []byte{}
+	sample = protowire.AppendTag(sample, sampleLocationID, protowire.BytesType)
+	sample = protowire.AppendBytes(sample, locations)
+	sample = protowire.AppendTag(sample, sampleValue, protowire.BytesType)
+	sample = protowire.AppendBytes(sample, packed)
+
+	b.samples = protowire.AppendTag(b.samples, profileSample, protowire.BytesType)
+	b.samples = protowire.AppendBytes(b.samples, sample)
+}
+
+func (b *pprofBuilder) valueType(kind, unit string) []byte {
+	valueType := []byte{}
+	valueType = appendVarint(valueType, valueTypeType, uint64(b.str(kind)))
+	valueType = appendVarint(valueType, valueTypeUnit, uint64(b.str(unit)))
+	return valueType
+}
+
+func appendVarint(b []byte, field protowire.Number, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+
+	b = protowire.AppendTag(b, field, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+// WritePprof writes the profiles as a gzipped profile.proto, for go tool
+// pprof.  Each stack of targets is a sample, with two sample types: the
+// number of calls to the last target in the stack, and its self time.  The
+// targets are the functions, using their makefile and line when the input
+// format records them.
+func WritePprof(w io.Writer, profiles []*parser.Profile) error {
+	b := newPprofBuilder()
+
+	sampleTypes := [][]byte{
+		b.valueType("calls", "count"),
+		b.valueType("time", "nanoseconds"),
+	}
+
+	var add func(profile *parser.Profile, node *analysis.Node, stack []uint64)
+	add = func(profile *parser.Profile, node *analysis.Node, stack []uint64) {
+		fn, found := profile.GetFunction(node.ID)
+		if !found {
+			return
+		}
+
+		stack = append(stack, b.location(fn))
+		b.sample(stack, int64(node.Calls), int64(node.Self))
+
+		for _, child := range node.Children {
+			add(profile, child, stack)
+		}
+	}
+
+	duration := int64(0)
+	comments := []int64{}
+
+	for _, profile := range profiles {
+		duration += int64(profile.TotalCost)
+
+		for _, comment := range []string{profile.Creator, profile.Command} {
+			if comment != "" {
+				comments = append(comments, b.str(comment))
+			}
+		}
+
+		nodes := analysis.Tree(profile)
+		analysis.SortTree(nodes, analysis.SortName)
+
+		for _, node := range nodes {
+			add(profile, node, nil)
+		}
+	}
+
+	// pprof expects the locations to be in a binary, so they are put in one
+	// for the build, which has the functions already symbolized
+	mapping := []byte{}
+	mapping = appendVarint(mapping, mappingID, 1)
+	mapping = appendVarint(mapping, mappingFilename, uint64(b.str("make")))
+	mapping = appendVarint(mapping, mappingHasFunctions, 1)
+
+	defaultType := b.str("time")
+
+	out := []byte{}
+	for _, sampleType := range sampleTypes {
+		out = protowire.AppendTag(out, profileSampleType, protowire.BytesType)
+		out = protowire.AppendBytes(out, sampleType)
+	}
+	out = append(out, b.samples...)
+	out = protowire.AppendTag(out, profileMapping, protowire.BytesType)
+	out = protowire.AppendBytes(out, mapping)
+	out = append(out, b.locations...)
+	out = append(out, b.functions...)
+	for _, value := range b.strings {
+		out = protowire.AppendTag(out, profileStringTable, protowire.BytesType)
+		out = protowire.AppendString(out, value)
+	}
+	out = appendVarint(out, profileDurationNanos, uint64(duration))
+	for _, comment := range comments {
+		out = appendVarint(out, profileComment, uint64(comment))
+	}
+	out = appendVarint(out, profileDefaultSampleType, uint64(defaultType))
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(out); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
@@ -0,0 +1,109 @@
+package convert
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"makeotel/parser"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fields reads the top level of a message, giving the varints and the bytes
+// of each field number.
+func fields(t *testing.T, message []byte) (map[protowire.Number][]uint64, map[protowire.Number][][]byte) {
+	varints := map[protowire.Number][]uint64{}
+	messages := map[protowire.Number][][]byte{}
+
+	for len(message) > 0 {
+		number, kind, n := protowire.ConsumeTag(message)
+		assert.GreaterOrEqual(t, n, 0)
+		message = message[n:]
+
+		switch kind {
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(message)
+			varints[number] = append(varints[number], value)
+			message = message[n:]
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(message)
+			messages[number] = append(messages[number], value)
+			message = message[n:]
+		default:
+			t.Fatalf("unexpected wire type %v", kind)
+		}
+	}
+
+	return varints, messages
+}
+
+func packed(message []byte) []uint64 {
+	values := []uint64{}
+	for len(message) > 0 {
+		value, n := protowire.ConsumeVarint(message)
+		values = append(values, value)
+		message = message[n:]
+	}
+	return values
+}
+
+func TestWritePprof(t *testing.T) {
+	out := &bytes.Buffer{}
+	assert.NoError(t, WritePprof(out, []*parser.Profile{loadProfile(t, "../example/callgrind.out.build-3")}))
+
+	gz, err := gzip.NewReader(out)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+
+	varints, messages := fields(t, content)
+
+	strs := []string{}
+	for _, s := range messages[profileStringTable] {
+		strs = append(strs, string(s))
+	}
+	assert.Equal(t, "", strs[0])
+
+	// calls, then time
+	assert.Len(t, messages[profileSampleType], 2)
+	typeVarints, _ := fields(t, messages[profileSampleType][1])
+	assert.Equal(t, "time", strs[typeVarints[valueTypeType][0]])
+	assert.Equal(t, "nanoseconds", strs[typeVarints[valueTypeUnit][0]])
+	assert.Equal(t, "time", strs[varints[profileDefaultSampleType][0]])
+
+	assert.Equal(t, uint64(9009500*time.Microsecond), varints[profileDurationNanos][0])
+	assert.Len(t, messages[profileFunction], 7)
+	assert.Len(t, messages[profileLocation], 7)
+
+	functions := map[uint64]string{}
+	for _, fn := range messages[profileFunction] {
+		fnVarints, _ := fields(t, fn)
+		functions[fnVarints[functionID][0]] = strs[fnVarints[functionName][0]]
+
+		if strs[fnVarints[functionName][0]] == "three.js" {
+			assert.Equal(t, "makefile", strs[fnVarints[functionFilename][0]])
+			assert.Equal(t, uint64(20), fnVarints[functionStartLine][0])
+		}
+	}
+
+	// a sample for each stack, leaf first
+	stacks := map[string][]uint64{}
+	for _, sample := range messages[profileSample] {
+		_, sampleMessages := fields(t, sample)
+
+		names := []string{}
+		for _, id := range packed(sampleMessages[sampleLocationID][0]) {
+			names = append(names, functions[id])
+		}
+
+		stacks[strings.Join(names, ";")] = packed(sampleMessages[sampleValue][0])
+	}
+
+	assert.Len(t, stacks, 7)
+	assert.Equal(t, []uint64{1, uint64(5003300 * time.Microsecond)}, stacks["three.js;two.js;build"])
+	assert.Equal(t, []uint64{0, uint64(300 * time.Microsecond)}, stacks["build"])
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"makeotel/parser"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertFormatAlias(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "build.svg")
+	conf := &config{inputFormat: parser.FormatAuto}
+
+	assert.NoError(t, runConvert(conf, &convertConfig{to: "flamegraph.svg", path: path}, []string{"example/callgrind.out.build-1"}))
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "<svg")
+
+	assert.Error(t, runConvert(conf, &convertConfig{to: "chrome.svg", path: path}, []string{"example/callgrind.out.build-1"}))
+}
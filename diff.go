@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"makeotel/analysis"
+	"makeotel/parser"
+	"makeotel/tracing"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type diffConfig struct {
+	format    string
+	threshold time.Duration
+}
+
+func diffFlags(defaults settings, conf *diffConfig) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("diff", pflag.ContinueOnError)
+	flags.StringVar(&conf.format, "output-format", defaults.String("diff.output-format", OutputText), "how to show the changes: text (a table), or json")
+	flags.DurationVar(&conf.threshold, "threshold", defaults.Duration("threshold", 0), "fail if any target's inclusive time grew by more than this, such as 30s.  0 never fails")
+
+	return flags
+}
+
+func diffCommand() *command {
+	diffConf := &diffConfig{}
+
+	return &command{
+		name:    "diff",
+		summary: "Compare two profiles, to find what got slower",
+		usage:   "makeotel diff [flags] <old_profile> <new_profile>",
+		description: `Compares two profiles of the same build, matching the targets by their id.
+Lists the targets which were added or removed, and the change in each target's
+self and inclusive times, largest change in self time first.  Targets which
+didn't change are left out.`,
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			input := inputFlags(defaults, conf)
+			input.MarkHidden("single-trace")
+
+			return []flagGroup{
+				{"Diff Flags", diffFlags(defaults, diffConf)},
+				{"Input Flags", input},
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: func(conf *config, otelConf *tracing.Config, args []string) error {
+			return runDiff(conf, diffConf, args, os.Stdout)
+		},
+	}
+}
+
+func runDiff(conf *config, diffConf *diffConfig, args []string, w io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("diff takes two arguments: old path, and new path")
+	}
+
+	if diffConf.format != OutputText && diffConf.format != OutputJson {
+		return fmt.Errorf("unknown output format %q, expected %s or %s", diffConf.format, OutputText, OutputJson)
+	}
+
+	profiles := make([]*parser.Profile, 0, len(args))
+	for _, path := range args {
+		profile, err := parseFile(path, conf.inputFormat)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		profiles = append(profiles, profile)
+	}
+
+	if err := attachProfiles(profiles, conf.attach); err != nil {
+		return err
+	}
+
+	before, after := profiles[0], profiles[1]
+
+	changes := []*analysis.Change{}
+	for _, change := range analysis.Diff(before, after) {
+		if change.Status != analysis.StatusUnchanged {
+			changes = append(changes, change)
+		}
+	}
+
+	var err error
+	if diffConf.format == OutputJson {
+		err = writeDiffJson(w, args, before, after, changes)
+	} else {
+		err = writeDiffTable(w, before, after, changes)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if diffConf.threshold > 0 {
+		slower := []string{}
+		for _, change := range changes {
+			if change.InclusiveDelta() > diffConf.threshold {
+				slower = append(slower, change.Name)
+			}
+		}
+
+		if len(slower) > 0 {
+			return fmt.Errorf("targets which got slower by more than %s: %s", diffConf.threshold, strings.Join(slower, ", "))
+		}
+	}
+
+	return nil
+}
+
+func writeDiffTable(w io.Writer, before, after *parser.Profile, changes []*analysis.Change) error {
+	fmt.Fprintf(w, "total: %s -> %s, %s\n\n", before.TotalCost, after.TotalCost, formatChange(before.TotalCost, after.TotalCost))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tSTATUS\tOLD SELF\tNEW SELF\tSELF CHANGE\tOLD INCLUSIVE\tNEW INCLUSIVE\tINCLUSIVE CHANGE")
+
+	for _, change := range changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			change.Name,
+			change.Status,
+			change.OldSelf,
+			change.NewSelf,
+			formatChange(change.OldSelf, change.NewSelf),
+			change.OldInclusive,
+			change.NewInclusive,
+			formatChange(change.OldInclusive, change.NewInclusive),
+		)
+	}
+
+	return tw.Flush()
+}
+
+// formatChange shows the change with its sign, and as a percentage when
+// there is something to compare it to.
+func formatChange(before, after time.Duration) string {
+	delta := after - before
+
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+		delta = -delta
+	}
+
+	if before == 0 {
+		return sign + delta.String()
+	}
+
+	return fmt.Sprintf("%s%s (%s%.1f%%)", sign, delta, sign, float64(delta)/float64(before)*100)
+}
+
+type diffResult struct {
+	Old      string        `json:"old"`
+	New      string        `json:"new"`
+	OldTotal float64       `json:"old_total_ms"`
+	NewTotal float64       `json:"new_total_ms"`
+	Targets  []*diffTarget `json:"targets"`
+}
+
+type diffTarget struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	OldSelf         float64 `json:"old_self_ms"`
+	NewSelf         float64 `json:"new_self_ms"`
+	SelfChange      float64 `json:"self_change_ms"`
+	OldInclusive    float64 `json:"old_inclusive_ms"`
+	NewInclusive    float64 `json:"new_inclusive_ms"`
+	InclusiveChange float64 `json:"inclusive_change_ms"`
+}
+
+func writeDiffJson(w io.Writer, paths []string, before, after *parser.Profile, changes []*analysis.Change) error {
+	result := diffResult{
+		Old:      paths[0],
+		New:      paths[1],
+		OldTotal: milliseconds(before.TotalCost),
+		NewTotal: milliseconds(after.TotalCost),
+		Targets:  make([]*diffTarget, 0, len(changes)),
+	}
+
+	for _, change := range changes {
+		result.Targets = append(result.Targets, &diffTarget{
+			ID:              change.ID,
+			Name:            change.Name,
+			Status:          change.Status,
+			OldSelf:         milliseconds(change.OldSelf),
+			NewSelf:         milliseconds(change.NewSelf),
+			SelfChange:      milliseconds(change.SelfDelta()),
+			OldInclusive:    milliseconds(change.OldInclusive),
+			NewInclusive:    milliseconds(change.NewInclusive),
+			InclusiveChange: milliseconds(change.InclusiveDelta()),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"makeotel/parser"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var diffPaths = []string{"example/callgrind.out.build-1", "example/callgrind.out.build-2"}
+
+func TestDiffThreshold(t *testing.T) {
+	conf := &config{inputFormat: parser.FormatAuto}
+
+	buffer := &bytes.Buffer{}
+	err := runDiff(conf, &diffConfig{format: OutputText, threshold: time.Millisecond}, diffPaths, buffer)
+	assert.EqualError(t, err, "targets which got slower by more than 1ms: two.js, build")
+	assert.Contains(t, buffer.String(), "two.js  added")
+
+	// the changes are still written when under the threshold
+	buffer.Reset()
+	assert.NoError(t, runDiff(conf, &diffConfig{format: OutputText, threshold: 2 * time.Second}, diffPaths, buffer))
+	assert.Contains(t, buffer.String(), "two.js  added")
+}
+
+func TestDiffAttachesToBoth(t *testing.T) {
+	conf := &config{inputFormat: parser.FormatAuto, attach: []string{"one.js=example/make-trace.log"}}
+
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, runDiff(conf, &diffConfig{format: OutputText}, diffPaths, buffer))
+	assert.NotContains(t, buffer.String(), "removed")
+}
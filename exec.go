@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"makeotel/parser"
+	"makeotel/tracing"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exitError carries the exit code of a wrapped command, so that makeotel can
+// exit with the same code without printing anything extra.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func execCommand() *command {
+	return &command{
+		name:    "exec",
+		summary: "Run make, and send its profile",
+		usage:   "makeotel exec [flags] -- <make_command> [make_args...]",
+		description: `Runs make with profiling enabled, then sends the profile to an OpenTelemetry
+collector.  The output and exit code of make are passed through, and the trace
+is sent even when the build fails.
+
+The command must be Remake (or a make which is Remake), as it is run with the
+--profile and --profile-directory flags.`,
+
+		// everything after the command name belongs to make, not to us
+		stopAtArgs: true,
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			trace := traceFlags(defaults, conf)
+
+			// the start time is recorded when make is run
+			trace.MarkHidden("timestamp")
+
+			input := inputFlags(defaults, conf)
+
+			// make is always remake, and there is only one profile
+			input.MarkHidden("input-format")
+			input.MarkHidden("single-trace")
+
+			return []flagGroup{
+				{"Input Flags", input},
+				{"Trace Flags", trace},
+				{"Output Flags", outputFlags(defaults, "exec", &conf.output)},
+				{"OpenTelemetry Flags", otelFlags(defaults, otelConf)},
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: runExec,
+	}
+}
+
+func runExec(conf *config, otelConf *tracing.Config, command []string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("exec needs a command to run, e.g. makeotel exec -- remake build")
+	}
+
+	if err := conf.checkTraceParent(); err != nil {
+		return err
+	}
+
+	// check the exporter configuration before the build, rather than failing
+	// after it has finished
+	if err := otelConf.ParseHeaders(); err != nil {
+		return err
+	}
+
+	if err := otelConf.ParseResourceAttributes(); err != nil {
+		return err
+	}
+
+	out, err := newReporter(&conf.output)
+	if err != nil {
+		return err
+	}
+	defer out.close()
+
+	dir, err := os.MkdirTemp("", "makeotel-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	shutdown, err := tracing.InitTracer(otelConf)
+	if err != nil {
+		return err
+	}
+
+	// the root span is started before make, so that its context can be passed
+	// to the tools run by the recipes
+	start := time.Now()
+	ctx := conf.traceContext(context.Background())
+	ctx, root := tracer().Start(ctx, conf.spanNamePrefix+filepath.Base(command[0]), trace.WithTimestamp(start))
+	root.SetAttributes(attribute.String("command", strings.Join(command, " ")))
+
+	pid, exitCode, err := runMake(command, dir, tracing.Environ(ctx))
+	if err != nil {
+		root.End()
+		shutdown()
+		return err
+	}
+	duration := time.Since(start)
+
+	// the failure is only recorded on the command's span, and not on the
+	// profile's root under it, so that a failed build is one error
+	if exitCode != 0 {
+		buildErr := fmt.Errorf("%s exited with code %d", command[0], exitCode)
+		root.RecordError(buildErr)
+		root.SetStatus(codes.Error, buildErr.Error())
+	}
+
+	if profile, err := findProfile(dir, pid); err != nil {
+		fmt.Fprintf(os.Stderr, "makeotel: unable to read the profile, only sending the %s span: %s\n", command[0], err)
+	} else {
+		if err := attachProfiles([]*parser.Profile{profile}, conf.attach); err != nil {
+			fmt.Fprintf(os.Stderr, "makeotel: %s\n", err)
+		}
+
+		exportProfiles(ctx, conf, []*profileFile{{path: command[0], profile: profile, start: start}})
+	}
+
+	root.End(trace.WithTimestamp(start.Add(duration)))
+	shutdown()
+
+	if err := out.report(command[0], strings.Join(command, " "), root.SpanContext()); err != nil {
+		return err
+	}
+
+	if exitCode != 0 {
+		return &exitError{code: exitCode}
+	}
+
+	return nil
+}
+
+// runMake runs the command with profiling written to dir, passing through
+// stdio, and adding env to its environment.  An error is only returned if the
+// command could not be run at all; a failing build is reported by the exit
+// code.
+func runMake(command []string, dir string, env []string) (int, int, error) {
+	args := append([]string{"--profile", "--profile-directory=" + dir}, command[1:]...)
+
+	cmd := exec.Command(command[0], args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(withoutTraceContext(os.Environ()), env...)
+
+	// make gets the interrupt from the terminal too; keep running so that
+	// whatever it managed to profile still gets sent
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return 0, 0, err
+	}
+
+	err := cmd.Wait()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return 0, 0, err
+	}
+
+	return cmd.Process.Pid, cmd.ProcessState.ExitCode(), nil
+}
+
+// findProfile reads the profile written by the top level make.  Recursive
+// makes write their own profiles, so prefer the one named after our child's
+// pid, and otherwise fall back to the last one written, as the top level
+// make is the last to finish.
+func findProfile(dir string, pid int) (*parser.Profile, error) {
+	path, err := findProfilePath(dir, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := parseFile(path, parser.FormatCallgrind)
+	if err == nil && len(profile.Roots()) == 0 {
+		err = fmt.Errorf("no root target found in %s", filepath.Base(path))
+	}
+
+	return profile, err
+}
+
+func findProfilePath(dir string, pid int) (string, error) {
+	expected := filepath.Join(dir, "callgrind.out."+strconv.Itoa(pid))
+	if _, err := os.Stat(expected); err == nil {
+		return expected, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "callgrind.out.*"))
+	if err != nil {
+		return "", err
+	}
+
+	// files which can't be read are left out, rather than failing to sort
+	files := []os.FileInfo{}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			files = append(files, info)
+		}
+	}
+
+	if len(files) == 0 {
+		return "", fmt.Errorf("no callgrind.out.* files were written to %s", dir)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	return filepath.Join(dir, files[len(files)-1].Name()), nil
+}
+
+// withoutTraceContext removes any trace context and baggage we were given, as
+// they are replaced by the context of the root span.
+func withoutTraceContext(env []string) []string {
+	filtered := make([]string, 0, len(env))
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "TRACEPARENT=") || strings.HasPrefix(kv, "TRACESTATE=") || strings.HasPrefix(kv, "BAGGAGE=") {
+			continue
+		}
+		filtered = append(filtered, kv)
+	}
+	return filtered
+}
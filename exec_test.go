@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"makeotel/parser"
+	"makeotel/tracing"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func profileCost(t *testing.T, path string) time.Duration {
+	profile, err := parseFile(path, parser.FormatCallgrind)
+	assert.NoError(t, err)
+	return profile.TotalCost
+}
+
+func TestFindProfileByPid(t *testing.T) {
+	dir := t.TempDir()
+	written := time.Unix(1_600_000_000, 0)
+
+	// the recursive make finished last, but isn't ours
+	copyProfile(t, dir, "example/callgrind.out.build-1", "callgrind.out.100", written)
+	copyProfile(t, dir, "example/callgrind.out.build-3", "callgrind.out.200", written.Add(time.Second))
+
+	profile, err := findProfile(dir, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, profileCost(t, "example/callgrind.out.build-1"), profile.TotalCost)
+}
+
+func TestFindProfileFallsBackToLastWritten(t *testing.T) {
+	dir := t.TempDir()
+	written := time.Unix(1_600_000_000, 0)
+
+	copyProfile(t, dir, "example/callgrind.out.build-3", "callgrind.out.100", written)
+	copyProfile(t, dir, "example/callgrind.out.build-1", "callgrind.out.200", written.Add(time.Second))
+
+	profile, err := findProfile(dir, 300)
+	assert.NoError(t, err)
+	assert.Equal(t, profileCost(t, "example/callgrind.out.build-1"), profile.TotalCost)
+
+	_, err = findProfile(t.TempDir(), 300)
+	assert.Error(t, err)
+}
+
+func TestWithoutTraceContext(t *testing.T) {
+	env := []string{"PATH=/bin", "TRACEPARENT=00-abc-def-01", "TRACESTATE=a=b", "BAGGAGE=k=v", "TRACEPARENTS=kept"}
+	assert.Equal(t, []string{"PATH=/bin", "TRACEPARENTS=kept"}, withoutTraceContext(env))
+}
+
+// writeStub writes a stand in for remake, which writes the profile to the
+// --profile-directory, named after its pid, and exits with code.
+func writeStub(t *testing.T, profile string, code int) string {
+	stub := filepath.Join(t.TempDir(), "remake")
+	assert.NoError(t, os.WriteFile(stub, []byte(`#!/bin/sh
+cp `+profile+` "${2#--profile-directory=}/callgrind.out.$$"
+exit `+strconv.Itoa(code)+`
+`), 0755))
+
+	return stub
+}
+
+func testExec(t *testing.T, profile string, code int) {
+	stub := writeStub(t, profile, code)
+
+	conf := &config{output: outputConfig{format: OutputText, path: filepath.Join(t.TempDir(), "report")}}
+	err := runExec(conf, &tracing.Config{Exporter: tracing.ExporterNone}, []string{stub, "build"})
+
+	var exitErr *exitError
+	assert.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, code, exitErr.code)
+
+	report, err := os.ReadFile(conf.output.path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(report), stub+" build")
+}
+
+func TestExecPassesThroughExitCode(t *testing.T) {
+	profile, err := filepath.Abs("example/callgrind.out.build-1")
+	assert.NoError(t, err)
+
+	testExec(t, profile, 3)
+}
+
+func TestExecProfileWithoutTargets(t *testing.T) {
+	// the headers are written before any targets are run
+	profile := filepath.Join(t.TempDir(), "callgrind.out.empty")
+	assert.NoError(t, os.WriteFile(profile, []byte("version: 1\ncreator: remake 4.3+dbg-1.5\npositions: line\nevents: 100usec\n"), 0644))
+
+	_, err := findProfile(filepath.Dir(profile), 0)
+	assert.ErrorContains(t, err, "no root target")
+
+	testExec(t, profile, 2)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"makeotel/parser"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// profileFile is a parsed profile, and when its build started.
+type profileFile struct {
+	path    string
+	profile *parser.Profile
+	start   time.Time
+}
+
+// expandPaths expands any globs which the shell didn't (e.g. because they
+// were quoted), and rejects globs which match nothing.
+func expandPaths(args []string) ([]string, error) {
+	paths := []string{}
+
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?[") {
+			paths = append(paths, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", arg)
+		}
+
+		paths = append(paths, matches...)
+	}
+
+	return paths, nil
+}
+
+// loadProfiles parses every file, ordered by when their builds started.  A
+// profile is written when make finishes, so each build is assumed to have
+// started TotalCost before its file was last modified.  The starts are then
+// shifted so the earliest one is at ts, keeping the gaps between them.
+//
+// Files which fail to parse are reported, and left out of the result.
+func loadProfiles(paths []string, format string, ts time.Time) ([]*profileFile, int) {
+	files := []*profileFile{}
+	failed := 0
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			failed++
+			continue
+		}
+
+		profile, err := parseFile(path, format)
+		if err == nil && len(profile.Roots()) == 0 {
+			err = fmt.Errorf("no root target found")
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			failed++
+			continue
+		}
+
+		files = append(files, &profileFile{
+			path:    path,
+			profile: profile,
+			start:   info.ModTime().Add(-profile.TotalCost),
+		})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].start.Before(files[j].start)
+	})
+
+	if len(files) > 0 {
+		offset := ts.Sub(files[0].start)
+		for _, file := range files {
+			file.start = file.start.Add(offset)
+		}
+	}
+
+	return files, failed
+}
+
+// attachProfiles parses each target=path pair, and puts the profile under the
+// target in every one of the profiles which has it, so that commands which
+// compare profiles see it in all of them.
+func attachProfiles(profiles []*parser.Profile, attach []string) error {
+	for _, pair := range attach {
+		target, path, found := strings.Cut(pair, "=")
+		if !found {
+			return fmt.Errorf("--attach expects target=path, but got %q", pair)
+		}
+
+		other, err := parseFile(path, parser.FormatAuto)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		attached := false
+		for _, profile := range profiles {
+			if _, err := profile.FindTarget(target); err != nil {
+				continue
+			}
+
+			if err := profile.Attach(target, other); err != nil {
+				return fmt.Errorf("unable to attach %s: %w", path, err)
+			}
+			attached = true
+		}
+
+		if !attached {
+			return fmt.Errorf("unable to attach %s, no profile has a target called %q", path, target)
+		}
+	}
+
+	return nil
+}
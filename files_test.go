@@ -0,0 +1,78 @@
+package main
+
+import (
+	"makeotel/parser"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func copyProfile(t *testing.T, dir, source, name string, modified time.Time) string {
+	content, err := os.ReadFile(source)
+	assert.NoError(t, err)
+
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, content, 0644))
+	assert.NoError(t, os.Chtimes(path, modified, modified))
+
+	return path
+}
+
+func TestLoadProfilesOrdersByStart(t *testing.T) {
+	dir := t.TempDir()
+	written := time.Unix(1_600_000_000, 0)
+
+	// build-3 takes ~9s and build-1 ~3s, so build-3 started first even though
+	// it was written a second later
+	late := copyProfile(t, dir, "example/callgrind.out.build-1", "callgrind.out.1", written)
+	early := copyProfile(t, dir, "example/callgrind.out.build-3", "callgrind.out.3", written.Add(time.Second))
+	copyProfile(t, dir, "example/makefile", "callgrind.out.broken", written)
+
+	paths, err := expandPaths([]string{filepath.Join(dir, "callgrind.out.*")})
+	assert.NoError(t, err)
+	assert.Len(t, paths, 3)
+
+	ts := time.Unix(1000, 0)
+	files, failed := loadProfiles(paths, parser.FormatAuto, ts)
+
+	assert.Equal(t, 1, failed)
+	assert.Len(t, files, 2)
+
+	assert.Equal(t, early, files[0].path)
+	assert.Equal(t, ts, files[0].start)
+
+	assert.Equal(t, late, files[1].path)
+	gap := files[0].profile.TotalCost - files[1].profile.TotalCost - time.Second
+	assert.Equal(t, ts.Add(gap), files[1].start)
+}
+
+func TestExpandPathsNoMatches(t *testing.T) {
+	_, err := expandPaths([]string{filepath.Join(t.TempDir(), "*.out")})
+	assert.Error(t, err)
+}
+
+func TestOrderProfiles(t *testing.T) {
+	files := []*profileFile{{path: "b/2"}, {path: "a/3"}, {path: "c/1"}}
+	paths := []string{"c/1", "a/3", "b/2"}
+
+	order := func() []string {
+		result := []string{}
+		for _, file := range files {
+			result = append(result, file.path)
+		}
+		return result
+	}
+
+	orderProfiles(files, paths, OrderArgs)
+	assert.Equal(t, []string{"c/1", "a/3", "b/2"}, order())
+
+	orderProfiles(files, paths, OrderName)
+	assert.Equal(t, []string{"c/1", "b/2", "a/3"}, order())
+
+	// time keeps the order from loadProfiles
+	orderProfiles(files, paths, OrderTime)
+	assert.Equal(t, []string{"c/1", "b/2", "a/3"}, order())
+}
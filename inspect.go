@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"makeotel/analysis"
+	"makeotel/parser"
+	"makeotel/tracing"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	ViewTable = "table"
+	ViewTree  = "tree"
+)
+
+type inspectConfig struct {
+	view string
+	sort string
+	top  int
+}
+
+func inspectFlags(defaults settings, conf *inspectConfig) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("inspect", pflag.ContinueOnError)
+	flags.StringVar(&conf.view, "view", defaults.String("view", ViewTable), "how to show the targets: table, or tree (each target under the ones which depend on it)")
+	flags.StringVar(&conf.sort, "sort", defaults.String("inspect.sort", analysis.SortInclusive), "the column to sort by: "+strings.Join(analysis.SortColumns, ", "))
+	flags.IntVar(&conf.top, "top", int(defaults.Int64("inspect.top", 0)), "only show the first N targets (or in a tree, the first N at each level).  0 shows them all")
+
+	return flags
+}
+
+func inspectCommand() *command {
+	inspectConf := &inspectConfig{}
+
+	return &command{
+		name:        "inspect",
+		summary:     "Show a summary of a profile, without sending it",
+		usage:       "makeotel inspect [flags] <path_to_profile>",
+		description: "Shows the time spent in each target of a profile, as a table or a tree.",
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			input := inputFlags(defaults, conf)
+			input.MarkHidden("single-trace")
+
+			return []flagGroup{
+				{"Inspect Flags", inspectFlags(defaults, inspectConf)},
+				{"Input Flags", input},
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: func(conf *config, otelConf *tracing.Config, args []string) error {
+			return runInspect(conf, inspectConf, args, os.Stdout)
+		},
+	}
+}
+
+func runInspect(conf *config, inspectConf *inspectConfig, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("inspect takes one argument: path")
+	}
+
+	if !contains(analysis.SortColumns, inspectConf.sort) {
+		return fmt.Errorf("unknown sort column %q, expected one of %s", inspectConf.sort, strings.Join(analysis.SortColumns, ", "))
+	}
+
+	profile, err := parseFile(args[0], conf.inputFormat)
+	if err != nil {
+		return err
+	}
+
+	if err := attachProfiles([]*parser.Profile{profile}, conf.attach); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tSELF\tINCLUSIVE\tCALLS\t%")
+
+	switch inspectConf.view {
+	case ViewTable:
+		targets := analysis.Targets(profile)
+		analysis.SortTargets(targets, inspectConf.sort)
+
+		for _, target := range limit(targets, inspectConf.top) {
+			writeTarget(tw, target.Name, target)
+		}
+
+	case ViewTree:
+		nodes := analysis.Tree(profile)
+		analysis.SortTree(nodes, inspectConf.sort)
+
+		writeTree(tw, limit(nodes, inspectConf.top), inspectConf.top, "")
+
+	default:
+		return fmt.Errorf("unknown view %q, expected %s or %s", inspectConf.view, ViewTable, ViewTree)
+	}
+
+	return tw.Flush()
+}
+
+func writeTree(tw *tabwriter.Writer, nodes []*analysis.Node, top int, indent string) {
+	for _, node := range nodes {
+		writeTarget(tw, indent+node.Name, &node.Target)
+		writeTree(tw, limit(node.Children, top), top, indent+"  ")
+	}
+}
+
+func writeTarget(tw *tabwriter.Writer, name string, target *analysis.Target) {
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.1f\n", name, target.Self, target.Inclusive, target.Calls, target.Percent)
+}
+
+func limit[T any](items []T, top int) []T {
+	if top > 0 && len(items) > top {
+		return items[:top]
+	}
+	return items
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package layout
+
+import (
+	"makeotel/parser"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Options are the naming rules for the spans.
+type Options struct {
+	NamePrefix string
+	BodySuffix string
+}
+
+// Span is a target placed on the timeline, which the exporters turn into an
+// OpenTelemetry span, or another tool's format.
+type Span struct {
+	// ID is the id of the span's target, which a body span shares
+	ID string
+
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes []attribute.KeyValue
+
+	// Body is set for the spans covering a target's own recipe, after its
+	// prerequisites are done
+	Body bool
+
+	Children []*Span
+}
+
+// Build lays out the profile's targets, starting at start.  Calls are laid
+// out one after another, unless the format recorded when each started; either
+// way, a target's own recipe runs after them.
+func Build(profile *parser.Profile, start time.Time, opts Options) *Span {
+	root := profile.Roots()[0]
+
+	span := build(profile, start, root, nil, opts)
+	span.Attributes = append(span.Attributes,
+		attribute.String("creator", profile.Creator),
+		attribute.String("command", profile.Command),
+	)
+
+	return span
+}
+
+func build(profile *parser.Profile, start time.Time, fn *parser.Function, call *parser.Call, opts Options) *Span {
+	calls := fn.Called
+	duration := profile.TotalCost
+	if call != nil {
+		calls = call.Calls
+		duration = call.Cost
+	}
+
+	span := &Span{
+		ID:    fn.ID,
+		Name:  opts.NamePrefix + fn.Name,
+		Start: start,
+		End:   start.Add(duration),
+		Attributes: []attribute.KeyValue{
+			attribute.String("module", fn.Module),
+			attribute.Int("called", calls),
+		},
+	}
+
+	nextStart := start
+	for _, call := range fn.Calls() {
+		if calledFn, found := profile.GetFunction(call.CalleeId); found {
+			callStart := nextStart
+			if fn.Timed {
+				callStart = start.Add(call.Start)
+			}
+
+			span.Children = append(span.Children, build(profile, callStart, calledFn, call, opts))
+
+			if end := callStart.Add(call.Cost); end.After(nextStart) {
+				nextStart = end
+			}
+		}
+	}
+
+	if callTotal := nextStart.Sub(start); call != nil && callTotal > 0 {
+		if workTime := call.Cost - callTotal; workTime > 0 {
+			span.Children = append(span.Children, &Span{
+				ID:    fn.ID,
+				Name:  opts.NamePrefix + fn.Name + opts.BodySuffix,
+				Start: nextStart,
+				End:   nextStart.Add(workTime),
+				Body:  true,
+			})
+		}
+	}
+
+	return span
+}
+
+// Walk calls visit for the span and each of its descendants, depth first.
+func (s *Span) Walk(visit func(span *Span, depth int)) {
+	s.walk(visit, 0)
+}
+
+func (s *Span) walk(visit func(span *Span, depth int), depth int) {
+	visit(s, depth)
+	for _, child := range s.Children {
+		child.walk(visit, depth+1)
+	}
+}
+
+// CriticalPath is the chain of targets which decided when the span finished:
+// starting from the span, each step is the prerequisite which finished last,
+// as the target's own recipe could not start until it did.
+func (s *Span) CriticalPath() []*Span {
+	path := []*Span{s}
+
+	for current := s; ; {
+		var last *Span
+		for _, child := range current.Children {
+			if !child.Body && (last == nil || child.End.After(last.End)) {
+				last = child
+			}
+		}
+
+		if last == nil {
+			return path
+		}
+
+		path = append(path, last)
+		current = last
+	}
+}
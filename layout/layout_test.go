@@ -0,0 +1,71 @@
+package layout
+
+import (
+	"makeotel/parser"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadProfile(t *testing.T, path string) *parser.Profile {
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	p, err := parser.NewParser(f, parser.FormatAuto)
+	assert.NoError(t, err)
+
+	profile, err := p.Parse()
+	assert.NoError(t, err)
+
+	return profile
+}
+
+func TestBuild(t *testing.T) {
+	start := time.Unix(1_600_000_000, 0)
+	root := Build(loadProfile(t, "../example/callgrind.out.build-3"), start, Options{NamePrefix: "make ", BodySuffix: "_body"})
+
+	assert.Equal(t, "make build", root.Name)
+	assert.Equal(t, start.Add(9009500*time.Microsecond), root.End)
+
+	// the prerequisites run one after another, followed by the body
+	two := root.Children[0]
+	assert.Equal(t, "make two.js", two.Name)
+	assert.Equal(t, start, two.Start)
+
+	three, twoTs, body := two.Children[0], two.Children[1], two.Children[2]
+	assert.Equal(t, "make three.js", three.Name)
+	assert.Equal(t, three.End, twoTs.Start)
+	assert.Equal(t, "make two.js_body", body.Name)
+	assert.True(t, body.Body)
+	assert.Equal(t, twoTs.End, body.Start)
+	assert.Equal(t, two.End, body.End)
+
+	one := root.Children[1]
+	assert.Equal(t, two.End, one.Start)
+}
+
+func TestBuildTimed(t *testing.T) {
+	start := time.Unix(1_600_000_000, 0)
+	root := Build(loadProfile(t, "../example/tsc-trace.json"), start, Options{BodySuffix: "_body"})
+
+	// the calls start when they were recorded, rather than one after another
+	check := root.Children[1]
+	assert.Equal(t, "checkSourceFile", check.Name)
+	assert.Equal(t, start.Add(115*time.Millisecond), check.Start)
+	assert.Equal(t, start.Add(120*time.Millisecond), check.Children[0].Start)
+}
+
+func TestCriticalPath(t *testing.T) {
+	root := Build(loadProfile(t, "../example/make-trace.log"), time.Unix(1_600_000_000, 0), Options{})
+
+	names := []string{}
+	for _, span := range root.CriticalPath() {
+		names = append(names, span.Name)
+	}
+
+	// one.js is built first, so two.js is the prerequisite which finished last
+	assert.Equal(t, []string{"make", "two.js", "three.js"}, names)
+}
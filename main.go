@@ -2,27 +2,38 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"makeotel/layout"
 	"makeotel/parser"
 	"makeotel/tracing"
-	"makeotel/version"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
 const OtlpEndpointEnvVar = "OTEL_EXPORTER_OTLP_ENDPOINT"
 const OtlpTracesEndpointEnvVar = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
 const MakeOtelDebugEnvVar = "MAKE_OTEL_DEBUG"
+const OtlpProtocolEnvVar = "OTEL_EXPORTER_OTLP_PROTOCOL"
+const OtlpTracesProtocolEnvVar = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
+const OtlpInsecureEnvVar = "OTEL_EXPORTER_OTLP_INSECURE"
+const OtelServiceNameEnvVar = "OTEL_SERVICE_NAME"
+const OtelResourceAttributesEnvVar = "OTEL_RESOURCE_ATTRIBUTES"
+const OtelTracesExporterEnvVar = "OTEL_TRACES_EXPORTER"
 
 func main() {
 	err := run(os.Args[1:])
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
+	}
+
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
@@ -30,44 +41,91 @@ func main() {
 }
 
 type config struct {
-	traceParent string
-	timestamp   int64
+	traceParent   string
+	requireParent bool
+	traceState    string
+	baggage       string
+	timestamp     int64
+
+	spanNamePrefix string
+	bodySpanSuffix string
+
+	inputFormat string
+	attach      []string
+	singleTrace bool
+
+	output outputConfig
 
-	version bool
-	help    bool
+	configFile string
+	version    bool
+	help       bool
 }
 
-func helpText() error {
-	maxWidth := 110
+func traceFlags(defaults settings, conf *config) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("trace", pflag.ContinueOnError)
+	flags.StringVar(&conf.traceParent, "trace-parent", defaults.String("trace-parent", "", "TRACEPARENT"), "the trace id to parent the spans to.  Can also be set by TRACEPARENT env var.")
+	flags.BoolVar(&conf.requireParent, "require-parent", defaults.Bool("require-parent", false), "fail if there is no trace parent, or it is invalid, rather than starting a new trace")
+	flags.StringVar(&conf.traceState, "trace-state", defaults.String("trace-state", "", "TRACESTATE"), "the W3C tracestate to go with --trace-parent.  Can also be set by TRACESTATE env var.")
+	flags.StringVar(&conf.baggage, "baggage", defaults.String("baggage", "", "BAGGAGE"), "W3C baggage, in the form k=v,k=v, to propagate and add to every span as attributes.  Can also be set by BAGGAGE env var.")
+	flags.Int64Var(&conf.timestamp, "timestamp", defaults.Int64("timestamp", time.Now().UTC().Unix()), "timestamp of when make was invoked, in unix epoch format")
+	flags.StringVar(&conf.spanNamePrefix, "span-name-prefix", defaults.String("span-name-prefix", ""), "a prefix to add to the name of every target's span")
+	flags.StringVar(&conf.bodySpanSuffix, "body-span-suffix", defaults.String("body-span-suffix", "_body"), "the suffix for the spans covering a target's own recipe, after its prerequisites")
 
-	sb := strings.Builder{}
-	sb.WriteString(`Turns a callgrind format profile from Remake into an OpenTelemetry Trace, and
-send it to an OpenTelemetry collector.
+	return flags
+}
 
-Usage:
+// layoutTraceFlags are the trace flags for the commands which lay out the spans
+// without sending them, for which only the naming and timing of the spans
+// applies.
+func layoutTraceFlags(defaults settings, conf *config) *pflag.FlagSet {
+	flags := traceFlags(defaults, conf)
+	flags.MarkHidden("trace-parent")
+	flags.MarkHidden("require-parent")
+	flags.MarkHidden("trace-state")
+	flags.MarkHidden("baggage")
 
-      makeotel [flags] <path_to_remake_profile>
+	return flags
+}
 
-Trace Flags:
+// traceContext gives ctx with the trace context we were asked to continue.
+func (c *config) traceContext(ctx context.Context) context.Context {
+	return tracing.WithTraceContext(ctx, c.traceParent, c.traceState, c.baggage)
+}
 
-` + traceFlags(&config{}).FlagUsagesWrapped(maxWidth) + `
+// checkTraceParent validates the trace parent.  An invalid one is ignored
+// with a warning, unless --require-parent is set, when it (or a missing one)
+// is an error.
+func (c *config) checkTraceParent() error {
+	if c.traceParent == "" {
+		if c.requireParent {
+			return fmt.Errorf("--require-parent is set, but no trace parent was given with --trace-parent or TRACEPARENT")
+		}
+		return nil
+	}
 
-OpenTelemetry Flags:
+	err := tracing.ValidateTraceParent(c.traceParent)
+	if err == nil {
+		return nil
+	}
 
-` + otelFlags(&tracing.Config{}).FlagUsagesWrapped(maxWidth) + `
+	if c.requireParent {
+		return fmt.Errorf("invalid trace parent %q: %w", c.traceParent, err)
+	}
 
-General Flags:
+	fmt.Fprintf(os.Stderr, "makeotel: ignoring invalid trace parent %q, and starting a new trace: %s\n", c.traceParent, err)
 
-` + commandFlags(&config{}).FlagUsagesWrapped(maxWidth))
+	// the state belongs to the parent, so goes with it
+	c.traceParent = ""
+	c.traceState = ""
 
-	fmt.Println(sb.String())
 	return nil
 }
 
-func traceFlags(conf *config) *pflag.FlagSet {
-	flags := pflag.NewFlagSet("trace", pflag.ContinueOnError)
-	flags.StringVar(&conf.traceParent, "trace-parent", os.Getenv("TRACEPARENT"), "the trace id to parent the spans to.  Can also be set by TRACEPARENT env var.")
-	flags.Int64Var(&conf.timestamp, "timestamp", time.Now().UTC().Unix(), "timestamp of when make was invoked, in unix epoch format")
+func inputFlags(defaults settings, conf *config) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("input", pflag.ContinueOnError)
+	flags.StringVar(&conf.inputFormat, "input-format", defaults.String("input-format", parser.FormatAuto), "the format of the profiles: "+strings.Join(parser.FormatNames(), ", ")+".  auto detects the format from the start of each file")
+	flags.StringArrayVar(&conf.attach, "attach", defaults.Pairs("attach"), "a profile to put under one of the targets (in every profile which has it), in the form target=path, such as the .ninja_log of the build a target's recipe runs.  Can be given several times")
+	flags.BoolVar(&conf.singleTrace, "single-trace", defaults.Bool("single-trace", false), "when sending several profiles, put them all in one trace under a parent span, rather than a trace each")
 
 	return flags
 }
@@ -75,149 +133,283 @@ func traceFlags(conf *config) *pflag.FlagSet {
 func commandFlags(conf *config) *pflag.FlagSet {
 	flags := pflag.NewFlagSet("commands", pflag.ContinueOnError)
 
+	flags.StringVar(&conf.configFile, "config", "", "a settings file to use instead of the "+SettingsFileName+" files in the project and home directories")
 	flags.BoolVar(&conf.version, "version", false, "print the version of this tool, and exit")
 	flags.BoolVar(&conf.help, "help", false, "print the help text, and exit")
 
 	return flags
 }
 
-func otelFlags(conf *tracing.Config) *pflag.FlagSet {
-
-	defaultEndpoint := "localhost:4317"
-	if val := os.Getenv(OtlpTracesEndpointEnvVar); val != "" {
-		defaultEndpoint = val
-	} else if val := os.Getenv(OtlpEndpointEnvVar); val != "" {
-		defaultEndpoint = val
-	}
+func otelFlags(defaults settings, conf *tracing.Config) *pflag.FlagSet {
 
-	defaultDebug := false
-	if val, err := strconv.ParseBool(os.Getenv(MakeOtelDebugEnvVar)); err == nil {
-		defaultDebug = val
-	}
+	defaultEndpoint := defaults.String("otlp-endpoint", "localhost:4317", OtlpTracesEndpointEnvVar, OtlpEndpointEnvVar)
+	defaultExporter := defaults.String("exporter", tracing.ExporterOtlp, OtelTracesExporterEnvVar)
+	defaultDebug := defaults.Bool("otlp-debug", false, MakeOtelDebugEnvVar)
+	defaultProtocol := defaults.String("otlp-protocol", tracing.ProtocolGrpc, OtlpTracesProtocolEnvVar, OtlpProtocolEnvVar)
+	defaultInsecure := defaults.Bool("otlp-insecure", false, OtlpInsecureEnvVar)
 
 	flags := pflag.NewFlagSet("otel", pflag.ContinueOnError)
 
-	flags.StringVar(&conf.Endpoint, "otlp-endpoint", defaultEndpoint, "A gRPC or HTTP endpoint to send traces to. Can also be set by "+OtlpEndpointEnvVar+" or "+OtlpTracesEndpointEnvVar+" env vars")
-	flags.StringSliceVar(&conf.HeadersRaw, "otlp-headers", []string{}, "key value pairs in the form k=v to set as headers")
+	flags.StringVar(&conf.Exporter, "exporter", defaultExporter, "Where to send spans: otlp, console (print a tree to stderr), or none. Can also be set by "+OtelTracesExporterEnvVar+" env var")
+	flags.StringVar(&conf.Endpoint, "otlp-endpoint", defaultEndpoint, "A gRPC or HTTP endpoint, or unix:///path/to/socket to send traces to. Can also be set by "+OtlpEndpointEnvVar+" or "+OtlpTracesEndpointEnvVar+" env vars")
+	flags.StringVar(&conf.Protocol, "otlp-protocol", defaultProtocol, "The protocol to use when the endpoint is not an http:// or https:// url: grpc or http/protobuf.  Can also be set by "+OtlpProtocolEnvVar+" or "+OtlpTracesProtocolEnvVar+" env vars")
+	flags.BoolVar(&conf.Insecure, "otlp-insecure", defaultInsecure, "Disable TLS for the exporter.  Loopback and unix socket gRPC endpoints, and http:// endpoints are always insecure.  Can also be set by "+OtlpInsecureEnvVar+" env var")
+	flags.StringSliceVar(&conf.HeadersRaw, "otlp-headers", defaults.Pairs("otlp-headers"), "key value pairs in the form k=v to set as headers")
+	flags.StringVar(&conf.ServiceName, "service-name", defaults.String("service-name", "", OtelServiceNameEnvVar), "The service.name resource attribute, defaults to "+tracing.DefaultServiceName+".  Can also be set by "+OtelServiceNameEnvVar+" env var")
+	flags.StringSliceVar(&conf.ResourceAttributesRaw, "resource-attributes", defaults.Pairs("resource-attributes", OtelResourceAttributesEnvVar), "key value pairs in the form k=v to add to the resource.  These are merged with, and override, the "+OtelResourceAttributesEnvVar+" env var")
+	flags.StringVar(&conf.HeadersFile, "otlp-headers-file", defaults.String("otlp-headers-file", ""), "a file of headers, one per line in the form k=v or k: v, to send to the endpoint")
+	flags.StringVar(&conf.BearerTokenFile, "otlp-bearer-token-file", defaults.String("otlp-bearer-token-file", ""), "a file containing a token to send as an Authorization: Bearer header")
+	flags.StringVar(&conf.BasicAuthUser, "otlp-basic-auth-user", defaults.String("otlp-basic-auth-user", ""), "the username to send as an Authorization: Basic header")
+	flags.StringVar(&conf.BasicAuthPasswordFile, "otlp-basic-auth-password-file", defaults.String("otlp-basic-auth-password-file", ""), "a file containing the password for --otlp-basic-auth-user")
 	flags.BoolVar(&conf.Debug, "otlp-debug", defaultDebug, "Set to true to see debug output from the OTEL Exporter.  Can also be set by "+MakeOtelDebugEnvVar+" env var")
 
 	return flags
 }
 
 func run(args []string) error {
-	conf := &config{}
-	otelConf := &tracing.Config{}
-
-	allFlags := []*pflag.FlagSet{
-		traceFlags(conf),
-		otelFlags(otelConf),
-		commandFlags(conf),
+	if err := loadSettings(args); err != nil {
+		return err
 	}
 
-	flags := pflag.NewFlagSet("makeotel", pflag.ContinueOnError)
-	for _, set := range allFlags {
-		flags.AddFlagSet(set)
+	if len(args) == 0 {
+		return helpText()
 	}
 
-	if err := flags.Parse(args); err != nil {
-		return err
+	if args[0] == "help" {
+		if len(args) > 1 {
+			if cmd := findCommand(args[1]); cmd != nil {
+				return cmd.helpText()
+			}
+		}
+		return helpText()
 	}
 
-	if conf.version {
-		fmt.Println(version.VersionNumber())
-		return nil
+	if cmd := findCommand(args[0]); cmd != nil {
+		return cmd.execute(args[1:])
 	}
 
-	if conf.help {
+	if args[0] == "--help" || args[0] == "-h" {
 		return helpText()
 	}
 
-	if flags.NArg() != 1 {
-		return fmt.Errorf("this program takes one argument: path")
+	// `makeotel [flags] <path>` predates the subcommands, so keep it working,
+	// but don't take a mistyped command for a profile
+	if isLegacySend(args[0]) {
+		return sendCommand().execute(args)
+	}
+
+	return fmt.Errorf("unknown command %q, see makeotel help", args[0])
+}
+
+// isLegacySend decides if the arguments are for send without its name, when
+// they start with a flag, a profile, or a glob.
+func isLegacySend(arg string) bool {
+	if strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "*?[") {
+		return true
+	}
+
+	_, err := os.Stat(arg)
+	return err == nil
+}
+
+func sendCommand() *command {
+	return &command{
+		name:    "send",
+		summary: "Send a profile to an OpenTelemetry collector",
+		usage:   "makeotel send [flags] <path_to_profile>...",
+		description: `Turns a callgrind format profile from Remake into an OpenTelemetry Trace, and
+send it to an OpenTelemetry collector.
+
+Several profiles (or globs, such as 'callgrind.out.*') can be given, such as
+from a recursive make.  They are ordered by when they were written, and each is
+sent as its own trace, or with --single-trace, under one parent span.`,
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			return []flagGroup{
+				{"Input Flags", inputFlags(defaults, conf)},
+				{"Trace Flags", traceFlags(defaults, conf)},
+				{"Output Flags", outputFlags(defaults, "send", &conf.output)},
+				{"OpenTelemetry Flags", otelFlags(defaults, otelConf)},
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: runSend,
+	}
+}
+
+func runSend(conf *config, otelConf *tracing.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("send takes at least one argument: path")
+	}
+
+	if err := conf.checkTraceParent(); err != nil {
+		return err
+	}
+
+	if err := parser.CheckFormat(conf.inputFormat); err != nil {
+		return err
 	}
 
 	if err := otelConf.ParseHeaders(); err != nil {
 		return err
 	}
 
-	file := flags.Arg(0)
-	f, err := os.Open(file)
+	if err := otelConf.ParseResourceAttributes(); err != nil {
+		return err
+	}
+
+	out, err := newReporter(&conf.output)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer out.close()
 
-	p := parser.NewCallgrindParser(f)
-	profile, err := p.Parse()
+	paths, err := expandPaths(args)
 	if err != nil {
 		return err
 	}
 
-	root := profile.Roots()[0]
-	fmt.Println(root.Name)
+	files, failed := loadProfiles(paths, conf.inputFormat, time.Unix(conf.timestamp, 0))
+
+	if err := attachProfiles(profilesOf(files), conf.attach); err != nil {
+		return err
+	}
+
+	if len(files) > 0 {
+		if err := send(conf, otelConf, out, files); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profiles could not be sent", failed, len(paths))
+	}
+
+	return nil
+}
+
+func parseFile(file string, format string) (*parser.Profile, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
+	p, err := parser.NewParser(f, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Parse()
+}
+
+// send exports each profile as a trace starting at the file's start time, or
+// with --single-trace, as siblings under one parent span.
+func send(conf *config, otelConf *tracing.Config, out *reporter, files []*profileFile) error {
 	shutdown, err := tracing.InitTracer(otelConf)
 	if err != nil {
 		return err
 	}
 
-	ts := time.Unix(conf.timestamp, 0)
-	ctx := tracing.WithTraceParent(context.Background(), conf.traceParent)
-	spans(ctx, profile, ts, root, nil)
+	ctx := conf.traceContext(context.Background())
+	sent := exportProfiles(ctx, conf, files)
 
 	shutdown()
 
+	for i, file := range files {
+		if err := out.report(file.path, file.profile.Roots()[0].Name, sent[i]); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-var tr = otel.Tracer("make-otel")
+// exportProfiles creates the spans for the profiles under the span in ctx,
+// with the already initialised tracer, returning the root span of each.
+func exportProfiles(ctx context.Context, conf *config, files []*profileFile) []trace.SpanContext {
+	// the span the build ran under, which the targets' span ids are worked
+	// out from
+	build := trace.SpanContextFromContext(ctx)
 
-func spans(ctx context.Context, profile *parser.Profile, start time.Time, fn *parser.Function, call *parser.Call) {
-	ctx, span := tr.Start(ctx, fn.Name, trace.WithTimestamp(start))
+	var parent trace.Span
+	if conf.singleTrace {
+		ctx, parent = tracer().Start(ctx, conf.spanNamePrefix+"make", trace.WithTimestamp(files[0].start))
+	}
+
+	sent := make([]trace.SpanContext, 0, len(files))
+	end := files[0].start
+	for _, file := range files {
+		sent = append(sent, spans(ctx, conf, file.profile, file.start, build))
+
+		if finish := file.start.Add(file.profile.TotalCost); finish.After(end) {
+			end = finish
+		}
+	}
 
-	calls := fn.Called
-	if call != nil {
-		calls = call.Calls
+	if parent != nil {
+		parent.End(trace.WithTimestamp(end))
 	}
 
-	span.SetAttributes(
-		attribute.String("module", fn.Module),
-		attribute.Int("called", calls),
-	)
+	return sent
+}
 
-	if call == nil {
-		// should be the root span
-		span.SetAttributes(
-			attribute.String("creator", profile.Creator),
-			attribute.String("command", profile.Command),
-		)
+// tracer is looked up each time, rather than once, as the global tracer
+// provider only passes on the first provider set to the tracers it has
+// already given out.
+func tracer() trace.Tracer {
+	return otel.Tracer("make-otel")
+}
+
+// spans exports the profile's layout as a trace, returning the root span.
+func spans(ctx context.Context, conf *config, profile *parser.Profile, start time.Time, build trace.SpanContext) trace.SpanContext {
+	root := layout.Build(profile, start, conf.layoutOptions())
+	return emit(ctx, root, build)
+}
+
+// emit exports the span and its children.  When the build ran under a span,
+// the span which a target's recipe ran in gets the id which `makeotel
+// traceparent` gave the recipe, so the spans of the tools it ran are under
+// it.
+func emit(ctx context.Context, s *layout.Span, build trace.SpanContext) trace.SpanContext {
+	startCtx := ctx
+	if build.IsValid() && runsRecipe(s) {
+		startCtx = tracing.WithTarget(ctx, build, s.ID)
 	}
 
-	nextStart := start
-	callTotal := time.Duration(0)
-	for _, call := range fn.Calls() {
-		if calledFn, found := profile.GetFunction(call.CalleeId); found {
-			spans(ctx, profile, nextStart, calledFn, call)
+	// the target is only for this span, not its children
+	_, span := tracer().Start(startCtx, s.Name, trace.WithTimestamp(s.Start))
+	ctx = trace.ContextWithSpan(ctx, span)
+	span.SetAttributes(s.Attributes...)
 
-			nextStart = nextStart.Add(call.Cost)
-			callTotal = callTotal + call.Cost
-		}
+	for _, child := range s.Children {
+		emit(ctx, child, build)
 	}
 
-	if call != nil && callTotal > 0 {
-		workTime := call.Cost - callTotal
+	span.End(trace.WithTimestamp(s.End))
 
-		if workTime > 0 {
-			_, s := tr.Start(ctx, fn.Name+"_body", trace.WithTimestamp(nextStart))
-			s.End(trace.WithTimestamp(nextStart.Add(workTime)))
-		}
+	return span.SpanContext()
+}
+
+// runsRecipe is true for the span covering a target's recipe: its body span,
+// or when it has none, the target's own span.
+func runsRecipe(s *layout.Span) bool {
+	if s.Body {
+		return true
 	}
 
-	duration := profile.TotalCost
-	if call != nil {
-		duration = call.Cost
+	for _, child := range s.Children {
+		if child.Body {
+			return false
+		}
 	}
 
-	span.End(trace.WithTimestamp(start.Add(duration)))
+	return true
+}
 
+func (c *config) layoutOptions() layout.Options {
+	return layout.Options{
+		NamePrefix: c.spanNamePrefix,
+		BodySuffix: c.bodySpanSuffix,
+	}
 }
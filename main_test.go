@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTraceParent(t *testing.T) {
+	cases := []struct {
+		name        string
+		conf        config
+		err         string
+		traceParent string
+		traceState  string
+	}{
+		{
+			name: "no parent",
+			conf: config{},
+		},
+		{
+			name:        "valid parent",
+			conf:        config{traceParent: buildParent, traceState: "a=b", requireParent: true},
+			traceParent: buildParent,
+			traceState:  "a=b",
+		},
+		{
+			name: "missing parent is required",
+			conf: config{requireParent: true},
+			err:  "--require-parent is set, but no trace parent was given",
+		},
+		{
+			name: "invalid parent is required",
+			conf: config{traceParent: "00-abc", traceState: "a=b", requireParent: true},
+			err:  `invalid trace parent "00-abc"`,
+		},
+		{
+			// the state belongs to the parent, so is dropped with it
+			name: "invalid parent is dropped",
+			conf: config{traceParent: "00-abc", traceState: "a=b"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.conf.checkTraceParent()
+
+			if c.err != "" {
+				assert.ErrorContains(t, err, c.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.traceParent, c.conf.traceParent)
+			assert.Equal(t, c.traceState, c.conf.traceState)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	OutputText = "text"
+	OutputJson = "json"
+)
+
+type outputConfig struct {
+	format      string
+	urlTemplate string
+	path        string
+}
+
+func outputFlags(defaults settings, command string, conf *outputConfig) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("output", pflag.ContinueOnError)
+	flags.StringVar(&conf.format, "output-format", defaults.String(command+".output-format", OutputText), "how to report the traces which were sent: text, or json (one object per line)")
+	flags.StringVar(&conf.urlTemplate, "trace-url-template", defaults.String("trace-url-template", ""), "a template for a link to the trace in your backend, such as https://jaeger/trace/{{.TraceID}}.  {{.SpanID}} is also available")
+	flags.StringVar(&conf.path, "output", defaults.String("output", "-"), "where to write the report of the traces, - for stdout")
+
+	return flags
+}
+
+// result is what gets reported for each trace sent.
+type result struct {
+	Path    string `json:"path"`
+	Name    string `json:"name"`
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+	URL     string `json:"url,omitempty"`
+}
+
+type reporter struct {
+	format   string
+	template *template.Template
+	w        io.Writer
+	close    func() error
+}
+
+func newReporter(conf *outputConfig) (*reporter, error) {
+	if conf.format != OutputText && conf.format != OutputJson {
+		return nil, fmt.Errorf("unknown output format %q, expected %s or %s", conf.format, OutputText, OutputJson)
+	}
+
+	r := &reporter{
+		format: conf.format,
+		w:      os.Stdout,
+		close:  func() error { return nil },
+	}
+
+	if conf.urlTemplate != "" {
+		tmpl, err := template.New("trace-url").Option("missingkey=error").Parse(conf.urlTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --trace-url-template: %w", err)
+		}
+
+		// find unknown fields now, rather than after the trace is sent
+		if err := tmpl.Execute(io.Discard, result{}); err != nil {
+			return nil, fmt.Errorf("invalid --trace-url-template: %w", err)
+		}
+		r.template = tmpl
+	}
+
+	if conf.path != "" && conf.path != "-" {
+		f, err := os.Create(conf.path)
+		if err != nil {
+			return nil, err
+		}
+		r.w = f
+		r.close = f.Close
+	}
+
+	return r, nil
+}
+
+func (r *reporter) report(path string, name string, sc trace.SpanContext) error {
+	res := result{
+		Path:    path,
+		Name:    name,
+		TraceID: sc.TraceID().String(),
+		SpanID:  sc.SpanID().String(),
+	}
+
+	if r.template != nil {
+		sb := strings.Builder{}
+		if err := r.template.Execute(&sb, res); err != nil {
+			return fmt.Errorf("invalid --trace-url-template: %w", err)
+		}
+		res.URL = sb.String()
+	}
+
+	if r.format == OutputJson {
+		return json.NewEncoder(r.w).Encode(res)
+	}
+
+	fmt.Fprintf(r.w, "%s: %s\n  trace %s, span %s\n", res.Path, res.Name, res.TraceID, res.SpanID)
+	if res.URL != "" {
+		fmt.Fprintf(r.w, "  %s\n", res.URL)
+	}
+
+	return nil
+}
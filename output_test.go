@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func testSpanContext(t *testing.T) trace.SpanContext {
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	assert.NoError(t, err)
+
+	return trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+}
+
+func TestReportText(t *testing.T) {
+	out, err := newReporter(&outputConfig{format: OutputText, urlTemplate: "https://jaeger/trace/{{.TraceID}}"})
+	assert.NoError(t, err)
+
+	buffer := &bytes.Buffer{}
+	out.w = buffer
+
+	assert.NoError(t, out.report("callgrind.out.1", "build", testSpanContext(t)))
+	assert.Equal(t, `callgrind.out.1: build
+  trace 4bf92f3577b34da6a3ce929d0e0e4736, span 00f067aa0ba902b7
+  https://jaeger/trace/4bf92f3577b34da6a3ce929d0e0e4736
+`, buffer.String())
+}
+
+func TestReportJson(t *testing.T) {
+	out, err := newReporter(&outputConfig{format: OutputJson, urlTemplate: "https://ui/{{.TraceID}}?span={{.SpanID}}"})
+	assert.NoError(t, err)
+
+	buffer := &bytes.Buffer{}
+	out.w = buffer
+
+	assert.NoError(t, out.report("callgrind.out.1", "build", testSpanContext(t)))
+	assert.JSONEq(t, `{
+		"path": "callgrind.out.1",
+		"name": "build",
+		"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id": "00f067aa0ba902b7",
+		"url": "https://ui/4bf92f3577b34da6a3ce929d0e0e4736?span=00f067aa0ba902b7"
+	}`, buffer.String())
+}
+
+func TestReporterRejectsBadTemplates(t *testing.T) {
+	_, err := newReporter(&outputConfig{format: OutputText, urlTemplate: "{{.Nope}}"})
+	assert.Error(t, err)
+
+	_, err = newReporter(&outputConfig{format: OutputText, urlTemplate: "{{"})
+	assert.Error(t, err)
+
+	_, err = newReporter(&outputConfig{format: "xml"})
+	assert.Error(t, err)
+}
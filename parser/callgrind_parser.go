@@ -45,6 +45,23 @@ func NewCallgrindParser(r io.Reader) *callgrindParser {
 	}
 }
 
+// detectCallgrind checks the first line of the file, which is either the
+// format's optional comment, or one of the version or creator headers.
+func detectCallgrind(header []byte) bool {
+	for _, line := range headerLines(header) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		return strings.HasPrefix(line, "# callgrind format") ||
+			strings.HasPrefix(line, "version:") ||
+			strings.HasPrefix(line, "creator:")
+	}
+
+	return false
+}
+
 func (p *callgrindParser) Parse() (*Profile, error) {
 	p.ReadLine()
 
@@ -381,7 +398,7 @@ func (p *callgrindParser) parseKey(key string) (string, bool) {
 
 func (p *callgrindParser) getCallee() *Function {
 	module := get(p.positions, "cob", "")
-	filename := get(p.positions, "cfi", "")
+	filename := get(p.positions, "cfl", "")
 	function := get(p.positions, "cfn", "")
 
 	return p.makeFunction(module, filename, function)
@@ -398,6 +415,10 @@ func (p *callgrindParser) getFunction() *Function {
 func (p callgrindParser) makeFunction(module, filename, name string) *Function {
 	id := name
 	if fn, ok := p.profile.GetFunction(id); ok {
+		// a function seen as a callee first might not have had its file
+		if fn.File == "" {
+			fn.File = filename
+		}
 		return fn
 	}
 
@@ -405,6 +426,7 @@ func (p callgrindParser) makeFunction(module, filename, name string) *Function {
 	if module != "" {
 		fn.Module = path.Base(module)
 	}
+	fn.File = filename
 
 	p.profile.addFunction(fn)
 	return fn
@@ -39,6 +39,7 @@ func TestParseFile(t *testing.T) {
 	assert.Equal(t, "build", build.Name)
 	assert.Equal(t, "", build.Module)
 	assert.Equal(t, int64(8), build.LineNumber)
+	assert.Equal(t, "makefile", build.File)
 	assert.Equal(t, 0, build.Called)
 	assert.Equal(t, 100*time.Microsecond, build.Cost)
 
@@ -104,6 +105,7 @@ func TestParseFile(t *testing.T) {
 	assert.Equal(t, "three.js", threejs.Name)
 	assert.Equal(t, "", threejs.Module)
 	assert.Equal(t, int64(20), threejs.LineNumber)
+	assert.Equal(t, "makefile", threejs.File)
 	assert.Equal(t, 1, threejs.Called)
 	assert.Equal(t, 5003400*time.Microsecond, threejs.Cost)
 
@@ -0,0 +1,356 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// chromeTraceParser reads the trace-event JSON written by Chrome, and tools
+// such as tsc --generateTrace and bazel --profile.  The events don't say
+// which called which, so they are nested by time on each thread, under a node
+// for the thread (when there is more than one), under a root for the trace.
+//
+// Only the complete (X) and begin/end (B/E) events are used; instant, counter,
+// async and flow events don't have a duration on a thread to show.
+type chromeTraceParser struct {
+	reader io.Reader
+
+	profile *Profile
+
+	// processes has the names from the process_name metadata events
+	processes map[string]string
+
+	// seen counts the spans given each id, so that repeats can be numbered
+	seen map[string]int
+}
+
+type chromeEvent struct {
+	Name  string      `json:"name"`
+	Cat   string      `json:"cat"`
+	Phase string      `json:"ph"`
+	Ts    float64     `json:"ts"`
+	Dur   float64     `json:"dur"`
+	Pid   interface{} `json:"pid"`
+	Tid   interface{} `json:"tid"`
+
+	Args map[string]interface{} `json:"args"`
+}
+
+type chromeSpan struct {
+	name  string
+	cat   string
+	start time.Duration
+	end   time.Duration
+	calls []*chromeSpan
+}
+
+type chromeThread struct {
+	key   string
+	name  string
+	spans []*chromeSpan
+}
+
+func NewChromeTraceParser(r io.Reader) *chromeTraceParser {
+	return &chromeTraceParser{
+		reader: r,
+		profile: &Profile{
+			functions: map[string]*Function{},
+		},
+		processes: map[string]string{},
+		seen:      map[string]int{},
+	}
+}
+
+func detectChromeTrace(header []byte) bool {
+	trimmed := bytes.TrimSpace(header)
+	if len(trimmed) == 0 || (trimmed[0] != '[' && trimmed[0] != '{') {
+		return false
+	}
+
+	return bytes.Contains(trimmed, []byte(`"traceEvents"`)) || bytes.Contains(trimmed, []byte(`"ph"`))
+}
+
+func (p *chromeTraceParser) Parse() (*Profile, error) {
+	events, err := p.readEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	threads := p.threads(events)
+	if len(threads) == 0 {
+		return nil, fmt.Errorf("no complete or begin/end events found in the trace")
+	}
+
+	p.profile.Creator = "chrome trace"
+	p.buildProfile(threads)
+
+	return p.profile, nil
+}
+
+// readEvents accepts both the array format, and the object format with the
+// events in traceEvents.
+func (p *chromeTraceParser) readEvents() ([]*chromeEvent, error) {
+	content, err := io.ReadAll(p.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*chromeEvent{}
+
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '{' {
+		wrapper := struct {
+			TraceEvents []*chromeEvent `json:"traceEvents"`
+		}{}
+
+		if err := json.Unmarshal(trimmed, &wrapper); err != nil {
+			return nil, fmt.Errorf("invalid trace: %w", err)
+		}
+
+		return wrapper.TraceEvents, nil
+	}
+
+	// the array format is allowed to be missing its closing bracket, as it's
+	// what a process which was killed leaves behind
+	if err := json.Unmarshal(content, &events); err != nil {
+		fixed := append(bytes.TrimRight(bytes.TrimSpace(content), ","), ']')
+		if json.Unmarshal(fixed, &events) != nil {
+			return nil, fmt.Errorf("invalid trace: %w", err)
+		}
+	}
+
+	return events, nil
+}
+
+// threads turns the events into spans for each thread, pairing up the begin
+// and end events.
+func (p *chromeTraceParser) threads(events []*chromeEvent) []*chromeThread {
+	threads := map[string]*chromeThread{}
+	order := []*chromeThread{}
+	open := map[string][]*chromeSpan{}
+
+	thread := func(event *chromeEvent) *chromeThread {
+		key := fmt.Sprintf("%v:%v", event.Pid, event.Tid)
+		if t, found := threads[key]; found {
+			return t
+		}
+
+		t := &chromeThread{key: key, name: fmt.Sprintf("pid %v tid %v", event.Pid, event.Tid)}
+		threads[key] = t
+		order = append(order, t)
+		return t
+	}
+
+	last := time.Duration(0)
+	for _, event := range events {
+		start := microseconds(event.Ts)
+
+		switch event.Phase {
+		case "X":
+			span := &chromeSpan{
+				name:  event.Name,
+				cat:   event.Cat,
+				start: start,
+				end:   start + microseconds(event.Dur),
+			}
+			t := thread(event)
+			t.spans = append(t.spans, span)
+
+			if span.end > last {
+				last = span.end
+			}
+
+		case "B":
+			t := thread(event)
+			span := &chromeSpan{
+				name:  event.Name,
+				cat:   event.Cat,
+				start: start,
+				end:   -1,
+			}
+			t.spans = append(t.spans, span)
+			open[t.key] = append(open[t.key], span)
+
+		case "E":
+			t := thread(event)
+			if stack := open[t.key]; len(stack) > 0 {
+				stack[len(stack)-1].end = start
+				open[t.key] = stack[:len(stack)-1]
+			}
+
+			if start > last {
+				last = start
+			}
+
+		case "M":
+			name, _ := event.Args["name"].(string)
+			if name == "" {
+				continue
+			}
+
+			switch event.Name {
+			case "thread_name":
+				thread(event).name = name
+			case "process_name":
+				p.processes[fmt.Sprint(event.Pid)] = name
+			}
+		}
+	}
+
+	result := []*chromeThread{}
+	for _, t := range order {
+		if len(t.spans) == 0 {
+			continue
+		}
+
+		// a begin without an end ran until the trace stopped
+		for _, span := range t.spans {
+			if span.end < 0 {
+				span.end = last
+			}
+		}
+
+		result = append(result, t)
+	}
+
+	// the threads are shown in the order they started
+	sort.SliceStable(result, func(i, j int) bool {
+		return firstStart(result[i].spans) < firstStart(result[j].spans)
+	})
+
+	return result
+}
+
+func firstStart(spans []*chromeSpan) time.Duration {
+	first := spans[0].start
+	for _, span := range spans {
+		if span.start < first {
+			first = span.start
+		}
+	}
+	return first
+}
+
+func microseconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Microsecond))
+}
+
+func (p *chromeTraceParser) buildProfile(threads []*chromeThread) {
+	first := threads[0].spans[0].start
+	last := first
+	for _, t := range threads {
+		for _, span := range t.spans {
+			if span.start < first {
+				first = span.start
+			}
+			if span.end > last {
+				last = span.end
+			}
+		}
+	}
+
+	p.profile.TotalCost = last - first
+
+	// name the root after the tool, when the trace is from a single process
+	name := "trace"
+	if len(p.processes) == 1 {
+		for _, process := range p.processes {
+			name = process
+		}
+	}
+
+	root := NewFunction(p.profile.uniqueID(name), name)
+	root.Cost = p.profile.TotalCost
+	root.Timed = true
+	p.profile.addFunction(root)
+
+	for _, t := range threads {
+		roots := nest(t.spans)
+
+		parent, parentStart := root, first
+		if len(threads) > 1 {
+			thread := &chromeSpan{name: t.name, start: roots[0].start, end: roots[0].end}
+			for _, span := range roots {
+				if span.end > thread.end {
+					thread.end = span.end
+				}
+			}
+
+			parent, parentStart = p.addSpan(root, thread, first), thread.start
+		}
+
+		for _, span := range roots {
+			p.addTree(parent, span, parentStart)
+		}
+	}
+}
+
+func (p *chromeTraceParser) addTree(parent *Function, span *chromeSpan, parentStart time.Duration) {
+	fn := p.addSpan(parent, span, parentStart)
+
+	for _, call := range span.calls {
+		p.addTree(fn, call, span.start)
+	}
+}
+
+// addSpan gives the span an id from its path from the root, such as
+// "tsc/Program/Check", so that the same span in another trace of the same
+// build has the same id.  Repeats of a name under the same parent are
+// numbered in the order they started, such as "tsc/Program/Check#2".
+func (p *chromeTraceParser) addSpan(parent *Function, span *chromeSpan, parentStart time.Duration) *Function {
+	id := parent.ID + "/" + span.name
+	p.seen[id]++
+	if n := p.seen[id]; n > 1 {
+		id = fmt.Sprintf("%s#%d", id, n)
+	}
+
+	fn := NewFunction(id, span.name)
+	fn.Module = span.cat
+	fn.Cost = span.end - span.start
+	fn.Called = 1
+	fn.Timed = true
+	p.profile.addFunction(fn)
+
+	parent.addCall(&Call{
+		CalleeId: fn.ID,
+		Calls:    1,
+		Cost:     fn.Cost,
+		Start:    span.start - parentStart,
+	})
+
+	return fn
+}
+
+// nest puts each span under the last one on the same thread which contains
+// it, returning the spans which aren't contained by any other.
+func nest(spans []*chromeSpan) []*chromeSpan {
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+
+	roots := []*chromeSpan{}
+	stack := []*chromeSpan{}
+
+	for _, span := range spans {
+		for len(stack) > 0 && !(span.start >= stack[len(stack)-1].start && span.end <= stack[len(stack)-1].end) {
+			stack = stack[:len(stack)-1]
+		}
+
+		if len(stack) == 0 {
+			roots = append(roots, span)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.calls = append(parent.calls, span)
+		}
+
+		stack = append(stack, span)
+	}
+
+	return roots
+}
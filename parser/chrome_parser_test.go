@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChromeTrace(t *testing.T) {
+	f, err := os.Open("../example/tsc-trace.json")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	profile, err := NewChromeTraceParser(f).Parse()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 300*time.Millisecond, profile.TotalCost)
+	assert.Len(t, profile.Roots(), 1)
+
+	root := profile.Roots()[0]
+	assert.Equal(t, "tsc", root.Name)
+
+	calls := root.Calls()
+	assert.Len(t, calls, 3)
+
+	program, _ := profile.GetFunction(calls[0].CalleeId)
+	assert.Equal(t, "createProgram", program.Name)
+	assert.Equal(t, "program", program.Module)
+	assert.Equal(t, 110*time.Millisecond, program.Cost)
+	assert.Len(t, program.Calls(), 2)
+
+	// the begin and end events are paired up, and the checkExpression inside
+	// them nested under checkSourceFile
+	check, _ := profile.GetFunction(calls[1].CalleeId)
+	assert.Equal(t, "checkSourceFile", check.Name)
+	assert.Equal(t, 115*time.Millisecond, calls[1].Start)
+	assert.Equal(t, 5*time.Millisecond, check.Calls()[0].Start)
+}
+
+func TestParseChromeTraceThreads(t *testing.T) {
+	trace := `{"traceEvents": [
+		{"name": "thread_name", "ph": "M", "pid": 1, "tid": 2, "args": {"name": "worker"}},
+		{"name": "build", "ph": "X", "ts": 0, "dur": 2000000, "pid": 1, "tid": 1},
+		{"name": "compile", "ph": "X", "ts": 500000, "dur": 1000000, "pid": 1, "tid": 2}
+	]}`
+
+	profile, err := NewChromeTraceParser(strings.NewReader(trace)).Parse()
+	assert.NoError(t, err)
+
+	root := profile.Roots()[0]
+	assert.Equal(t, "trace", root.Name)
+	assert.Equal(t, 2*time.Second, profile.TotalCost)
+
+	calls := root.Calls()
+	assert.Len(t, calls, 2)
+
+	worker, _ := profile.GetFunction(calls[1].CalleeId)
+	assert.Equal(t, "worker", worker.Name)
+	assert.Equal(t, 500*time.Millisecond, calls[1].Start)
+	assert.Equal(t, time.Second, calls[1].Cost)
+}
+
+func TestParseChromeTraceTruncated(t *testing.T) {
+	trace := `[
+		{"name": "build", "ph": "B", "ts": 0, "pid": 1, "tid": 1},
+		{"name": "compile", "ph": "X", "ts": 100, "dur": 400, "pid": 1, "tid": 1},`
+
+	profile, err := NewChromeTraceParser(strings.NewReader(trace)).Parse()
+	assert.NoError(t, err)
+
+	// the build never ended, so it runs until the last event
+	build, _ := profile.GetFunction(profile.Roots()[0].Calls()[0].CalleeId)
+	assert.Equal(t, "build", build.Name)
+	assert.Equal(t, 500*time.Microsecond, build.Cost)
+}
+
+func TestParseChromeTraceIDs(t *testing.T) {
+	ids := func(trace string) []string {
+		profile, err := NewChromeTraceParser(strings.NewReader(trace)).Parse()
+		assert.NoError(t, err)
+
+		result := []string{}
+		for _, fn := range profile.Functions() {
+			result = append(result, fn.ID)
+		}
+		return result
+	}
+
+	// the same build, with its events in another order, and an extra one
+	// which moves the others' positions
+	before := ids(`[
+		{"name": "process_name", "ph": "M", "pid": 1, "args": {"name": "tsc"}},
+		{"name": "check", "ph": "X", "ts": 0, "dur": 100, "pid": 1, "tid": 1},
+		{"name": "checkFile", "ph": "X", "ts": 10, "dur": 20, "pid": 1, "tid": 1},
+		{"name": "checkFile", "ph": "X", "ts": 50, "dur": 20, "pid": 1, "tid": 1}
+	]`)
+	after := ids(`[
+		{"name": "process_name", "ph": "M", "pid": 1, "args": {"name": "tsc"}},
+		{"name": "parse", "ph": "X", "ts": 0, "dur": 10, "pid": 1, "tid": 1},
+		{"name": "checkFile", "ph": "X", "ts": 60, "dur": 30, "pid": 1, "tid": 1},
+		{"name": "checkFile", "ph": "X", "ts": 20, "dur": 30, "pid": 1, "tid": 1},
+		{"name": "check", "ph": "X", "ts": 10, "dur": 90, "pid": 1, "tid": 1}
+	]`)
+
+	assert.ElementsMatch(t, []string{"tsc", "tsc/check", "tsc/check/checkFile", "tsc/check/checkFile#2"}, before)
+	assert.ElementsMatch(t, append(before, "tsc/parse"), after)
+}
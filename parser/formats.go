@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	FormatAuto        = "auto"
+	FormatCallgrind   = "callgrind"
+	FormatMake        = "make"
+	FormatNinja       = "ninja"
+	FormatChromeTrace = "chrome-trace"
+)
+
+// headerSize is how much of a file is read to detect its format.
+const headerSize = 16 * 1024
+
+// ProfileParser reads a profile from one of the input formats.
+type ProfileParser interface {
+	Parse() (*Profile, error)
+}
+
+// Format is an input format, which can be detected from the start of a file.
+type Format struct {
+	Name   string
+	Detect func(header []byte) bool
+	New    func(r io.Reader) ProfileParser
+}
+
+var formats = []*Format{
+	{
+		Name:   FormatCallgrind,
+		Detect: detectCallgrind,
+		New:    func(r io.Reader) ProfileParser { return NewCallgrindParser(r) },
+	},
+	{
+		Name:   FormatMake,
+		Detect: detectMake,
+		New:    func(r io.Reader) ProfileParser { return NewMakeParser(r) },
+	},
+	{
+		Name:   FormatNinja,
+		Detect: detectNinja,
+		New:    func(r io.Reader) ProfileParser { return NewNinjaParser(r) },
+	},
+	{
+		Name:   FormatChromeTrace,
+		Detect: detectChromeTrace,
+		New:    func(r io.Reader) ProfileParser { return NewChromeTraceParser(r) },
+	},
+}
+
+// Register adds an input format, replacing any existing format with the
+// same name.  Formats are detected in the order they were registered.
+func Register(format *Format) {
+	for i, existing := range formats {
+		if existing.Name == format.Name {
+			formats[i] = format
+			return
+		}
+	}
+
+	formats = append(formats, format)
+}
+
+// FormatNames lists the formats which can be given to NewParser.
+func FormatNames() []string {
+	names := []string{FormatAuto}
+	for _, format := range formats {
+		names = append(names, format.Name)
+	}
+	return names
+}
+
+// CheckFormat reports an error if the name is not auto, or one of the
+// registered formats.
+func CheckFormat(name string) error {
+	if name == "" || name == FormatAuto || findFormat(name) != nil {
+		return nil
+	}
+
+	return fmt.Errorf("unknown input format %q, expected one of %s", name, strings.Join(FormatNames(), ", "))
+}
+
+func findFormat(name string) *Format {
+	for _, format := range formats {
+		if format.Name == name {
+			return format
+		}
+	}
+	return nil
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// NewParser creates a parser for the format, or when the format is auto (or
+// empty), for whichever format the start of the reader matches.  Gzipped
+// files, such as bazel's profiles, are decompressed first.
+func NewParser(r io.Reader, name string) (ProfileParser, error) {
+	if err := CheckFormat(name); err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReaderSize(r, headerSize)
+	header, err := buffered.Peek(headerSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(header, gzipMagic) {
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewParser(decompressed, name)
+	}
+
+	if format := findFormat(name); format != nil {
+		return format.New(buffered), nil
+	}
+
+	for _, format := range formats {
+		if format.Detect(header) {
+			return format.New(buffered), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unable to detect the input format, expected one of %s", strings.Join(FormatNames()[1:], ", "))
+}
+
+// headerLines splits the header into lines, dropping the last one if the
+// header has cut it short.
+func headerLines(header []byte) []string {
+	lines := strings.Split(string(header), "\n")
+	if len(header) == headerSize {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
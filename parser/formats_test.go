@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormat(t *testing.T) {
+	cases := map[string]string{
+		"../example/callgrind.out.build-3": "*parser.callgrindParser",
+		"../example/make-debug.log":        "*parser.makeParser",
+		"../example/make-trace.log":        "*parser.makeParser",
+		"../example/.ninja_log":            "*parser.ninjaParser",
+		"../example/tsc-trace.json":        "*parser.chromeTraceParser",
+	}
+
+	for path, expected := range cases {
+		t.Run(path, func(t *testing.T) {
+			f, err := os.Open(path)
+			assert.NoError(t, err)
+			defer f.Close()
+
+			p, err := NewParser(f, FormatAuto)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, typeName(p))
+
+			// the detection must not lose the start of the file
+			profile, err := p.Parse()
+			assert.NoError(t, err)
+			assert.Len(t, profile.Roots(), 1)
+		})
+	}
+}
+
+func TestDetectGzipped(t *testing.T) {
+	content, err := os.ReadFile("../example/tsc-trace.json")
+	assert.NoError(t, err)
+
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	writer.Write(content)
+	writer.Close()
+
+	p, err := NewParser(compressed, FormatAuto)
+	assert.NoError(t, err)
+	assert.Equal(t, "*parser.chromeTraceParser", typeName(p))
+
+	profile, err := p.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, "tsc", profile.Roots()[0].Name)
+}
+
+func TestDetectUnknownFormat(t *testing.T) {
+	_, err := NewParser(strings.NewReader("build: one.js two.js\n"), FormatAuto)
+	assert.ErrorContains(t, err, "unable to detect")
+}
+
+func TestExplicitFormat(t *testing.T) {
+	p, err := NewParser(strings.NewReader(""), FormatMake)
+	assert.NoError(t, err)
+	assert.Equal(t, "*parser.makeParser", typeName(p))
+
+	_, err = NewParser(strings.NewReader(""), "bazel")
+	assert.ErrorContains(t, err, `unknown input format "bazel"`)
+}
+
+type testParser struct{}
+
+func (p *testParser) Parse() (*Profile, error) {
+	return &Profile{Creator: "test"}, nil
+}
+
+func TestRegister(t *testing.T) {
+	existing := formats
+	defer func() { formats = existing }()
+
+	Register(&Format{
+		Name:   "test",
+		Detect: func(header []byte) bool { return strings.HasPrefix(string(header), "test") },
+		New:    func(r io.Reader) ProfileParser { return &testParser{} },
+	})
+
+	assert.Contains(t, FormatNames(), "test")
+
+	p, err := NewParser(strings.NewReader("test profile"), FormatAuto)
+	assert.NoError(t, err)
+
+	profile, _ := p.Parse()
+	assert.Equal(t, "test", profile.Creator)
+}
+
+func typeName(v interface{}) string {
+	return fmt.Sprintf("%T", v)
+}
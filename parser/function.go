@@ -1,6 +1,9 @@
 package parser
 
-import "time"
+import (
+	"sort"
+	"time"
+)
 
 func NewFunction(id string, name string) *Function {
 	return &Function{
@@ -17,27 +20,93 @@ type Function struct {
 	ID         string
 	Name       string
 	Module     string
+	File       string
 	LineNumber int64
 	Called     int
 
 	Cost  time.Duration
 	calls map[string]*Call
+
+	// Timed is set when the format records when each call started, so they
+	// can be laid out in parallel using Call.Start, rather than one after
+	// another
+	Timed bool
+
+	// callOrder keeps the calls in the order they were added, which is the
+	// order make built them in
+	callOrder []string
 }
 
 func (f *Function) addCall(c *Call) {
+	if _, found := f.calls[c.CalleeId]; !found {
+		f.callOrder = append(f.callOrder, c.CalleeId)
+	}
 	f.calls[c.CalleeId] = c
 }
 
 func (f *Function) Calls() []*Call {
 	calls := make([]*Call, 0, len(f.calls))
 
-	for _, call := range f.calls {
-		calls = append(calls, call)
+	for _, id := range f.callOrder {
+		calls = append(calls, f.calls[id])
 	}
 
 	return calls
 }
 
+// copy gives a copy of the function, and its calls, with the ids prefixed.
+func (f *Function) copy(prefix string) *Function {
+	fn := NewFunction(prefix+f.ID, f.Name)
+	fn.Module = f.Module
+	fn.File = f.File
+	fn.LineNumber = f.LineNumber
+	fn.Called = f.Called
+	fn.Cost = f.Cost
+	fn.Timed = f.Timed
+
+	for _, call := range f.Calls() {
+		copied := *call
+		copied.CalleeId = prefix + call.CalleeId
+		fn.addCall(&copied)
+	}
+
+	return fn
+}
+
+// CallTime is how long the function spent in its calls; for a timed function
+// this is the time when at least one call was running, as they can overlap.
+func (f *Function) CallTime() time.Duration {
+	calls := f.Calls()
+
+	if !f.Timed {
+		total := time.Duration(0)
+		for _, call := range calls {
+			total += call.Cost
+		}
+		return total
+	}
+
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Start < calls[j].Start
+	})
+
+	total := time.Duration(0)
+	covered := time.Duration(0)
+	for _, call := range calls {
+		start := call.Start
+		if start < covered {
+			start = covered
+		}
+
+		if end := call.Start + call.Cost; end > start {
+			total += end - start
+			covered = end
+		}
+	}
+
+	return total
+}
+
 type Call struct {
 	CalleeId string
 	// ratio     float64
@@ -45,4 +114,8 @@ type Call struct {
 
 	Calls int
 	Cost  time.Duration
+
+	// Start is when the call started, relative to the start of the caller,
+	// and is only set when the caller is Timed
+	Start time.Duration
 }
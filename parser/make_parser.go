@@ -0,0 +1,486 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// makeParser builds a Profile from the output of GNU make's --trace or -d
+// (--debug) flags.  Neither has any timings, so each line must be prefixed
+// with a timestamp by a wrapper, such as `make -d 2>&1 | ts '%.s'`.
+//
+// The -d output has the whole target graph, as make prints when it starts and
+// finishes considering each target.  The --trace output only names the
+// prerequisites which caused a target to be remade, so targets remade for
+// another reason (such as phony targets) end up as roots, and each target is
+// assumed to run until the next one starts.
+type makeParser struct {
+	*lineParser
+
+	profile *Profile
+	creator string
+
+	targets map[string]*makeTarget
+	order   []*makeTarget
+
+	// considering is the stack of targets make is checking the prerequisites
+	// of, and scopes are the sub-makes run by recipes
+	considering []*makeTarget
+	scopes      []*makeScope
+	running     *makeTarget
+
+	updatingMakefiles bool
+	debug             bool
+
+	timed bool
+	first time.Time
+	last  time.Time
+}
+
+type makeTarget struct {
+	id     string
+	name   string
+	module string
+	file   string
+	line   int64
+
+	start time.Time
+	end   time.Time
+	ended bool
+
+	// traced is when --trace reported the recipe started
+	traced    time.Time
+	hasTraced bool
+
+	parents  []*makeTarget
+	children []*makeTarget
+
+	// scopeParent is the target whose recipe ran the sub-make this target
+	// is in
+	scopeParent *makeTarget
+}
+
+type makeScope struct {
+	dir         string
+	parent      *makeTarget
+	considering []*makeTarget
+}
+
+func NewMakeParser(r io.Reader) *makeParser {
+	return &makeParser{
+		lineParser: NewLineParser(r),
+		profile: &Profile{
+			functions: map[string]*Function{},
+		},
+
+		targets: map[string]*makeTarget{},
+	}
+}
+
+const quoted = "[`']([^']+)'"
+
+var (
+	timestampRx = regexp.MustCompile(`^\[?(\d{4}-\d\d-\d\dT[^ \]]+|\d+:\d\d:\d\d(?:\.\d+)?|\d+(?:\.\d+)?)\]?(?:\s+(.*))?$`)
+
+	versionRx     = regexp.MustCompile(`^GNU Make \S+$`)
+	considerRx    = regexp.MustCompile(`^Considering target file ` + quoted + `\.$`)
+	finishedRx    = regexp.MustCompile(`^Finished prerequisites of target file ` + quoted + `\.$`)
+	mustRemakeRx  = regexp.MustCompile(`^Must remake target ` + quoted + `\.$`)
+	remadeRx      = regexp.MustCompile(`^(?:Successfully remade target file|No need to remake target) ` + quoted)
+	enteringRx    = regexp.MustCompile(`^\S*make(?:\[\d+\])?: Entering directory ` + quoted)
+	leavingRx     = regexp.MustCompile(`^\S*make(?:\[\d+\])?: Leaving directory ` + quoted)
+	traceTargetRx = regexp.MustCompile(`^(.+?):(\d+): (?:update )?target ` + quoted + `(?: due to: (.*)| does not exist)?$`)
+)
+
+// detectMake looks for any of the lines which only make prints, as the
+// output of the recipes comes between them.
+func detectMake(header []byte) bool {
+	for _, line := range headerLines(header) {
+		line = strings.TrimSpace(line)
+		if groups := timestampRx.FindStringSubmatch(line); groups != nil {
+			line = groups[2]
+		}
+
+		if versionRx.MatchString(line) || considerRx.MatchString(line) || traceTargetRx.MatchString(line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *makeParser) Parse() (*Profile, error) {
+	p.ReadLine()
+
+	for !p.Eof() {
+		p.parseLine(p.Consume())
+	}
+
+	if !p.timed {
+		return nil, fmt.Errorf("no timestamps found, each line of the make output needs a timestamp prefix, such as from `ts '%%.s'`")
+	}
+
+	if len(p.order) == 0 {
+		return nil, fmt.Errorf("no targets found, expected the output of make --trace or make -d")
+	}
+
+	p.buildProfile()
+
+	return p.profile, nil
+}
+
+func (p *makeParser) parseLine(line string) {
+	line = p.parseTimestamp(line)
+
+	if versionRx.MatchString(line) {
+		p.creator = line
+		return
+	}
+
+	if line == "Updating makefiles...." {
+		p.updatingMakefiles = true
+		return
+	}
+
+	if line == "Updating goal targets...." {
+		p.updatingMakefiles = false
+		return
+	}
+
+	// make considers whether to remake the makefiles themselves before the
+	// goals, which isn't part of the build
+	if p.updatingMakefiles {
+		return
+	}
+
+	if groups := enteringRx.FindStringSubmatch(line); groups != nil {
+		p.scopes = append(p.scopes, &makeScope{dir: groups[1], parent: p.running, considering: p.considering})
+		p.considering = nil
+		return
+	}
+
+	if groups := leavingRx.FindStringSubmatch(line); groups != nil && len(p.scopes) > 0 {
+		scope := p.scopes[len(p.scopes)-1]
+		p.scopes = p.scopes[:len(p.scopes)-1]
+		p.considering = scope.considering
+		p.running = scope.parent
+		return
+	}
+
+	if groups := considerRx.FindStringSubmatch(line); groups != nil {
+		p.debug = true
+		p.consider(groups[1])
+		return
+	}
+
+	if groups := finishedRx.FindStringSubmatch(line); groups != nil {
+		target := p.target(groups[1])
+		for i := len(p.considering) - 1; i >= 0; i-- {
+			if p.considering[i] == target {
+				p.considering = p.considering[:i]
+				break
+			}
+		}
+		return
+	}
+
+	if groups := mustRemakeRx.FindStringSubmatch(line); groups != nil {
+		p.running = p.target(groups[1])
+		return
+	}
+
+	if groups := remadeRx.FindStringSubmatch(line); groups != nil {
+		target := p.target(groups[1])
+		target.end = p.last
+		target.ended = true
+		return
+	}
+
+	if groups := traceTargetRx.FindStringSubmatch(line); groups != nil {
+		p.trace(groups[3], groups[1], groups[2], groups[4])
+		return
+	}
+}
+
+// parseTimestamp removes the timestamp from the start of the line.  Lines
+// without one, such as the continuation of a recipe's output, are given the
+// time of the line before.
+func (p *makeParser) parseTimestamp(line string) string {
+	groups := timestampRx.FindStringSubmatch(line)
+	if groups == nil {
+		return line
+	}
+
+	ts, ok := parseTimestamp(groups[1])
+	if !ok {
+		return line
+	}
+
+	if !p.timed {
+		p.first = ts
+		p.timed = true
+	}
+	p.last = ts
+
+	return groups[2]
+}
+
+// parseTimestamp handles RFC3339 times, seconds (since the epoch, or since
+// the build started), and elapsed HH:MM:SS times, all with optional fractions.
+func parseTimestamp(value string) (time.Time, bool) {
+	if strings.Contains(value, "T") {
+		ts, err := time.Parse(time.RFC3339Nano, value)
+		return ts, err == nil
+	}
+
+	if parts := strings.Split(value, ":"); len(parts) == 3 {
+		hours, _ := strconv.Atoi(parts[0])
+		minutes, _ := strconv.Atoi(parts[1])
+		seconds, ok := parseSeconds(parts[2])
+		return time.Unix(0, 0).Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + seconds), ok
+	}
+
+	seconds, ok := parseSeconds(value)
+	return time.Unix(0, 0).Add(seconds), ok
+}
+
+// parseSeconds parses the whole and fractional parts separately, as a float
+// can't hold an epoch time to the microsecond.
+func parseSeconds(value string) (time.Duration, bool) {
+	whole, fraction, _ := strings.Cut(value, ".")
+
+	seconds, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	nanos := int64(0)
+	if fraction != "" {
+		if len(fraction) > 9 {
+			fraction = fraction[:9]
+		}
+		nanos, err = strconv.ParseInt(fraction+strings.Repeat("0", 9-len(fraction)), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+	}
+
+	return time.Duration(seconds)*time.Second + time.Duration(nanos), true
+}
+
+func (p *makeParser) consider(name string) {
+	target, found := p.lookup(name)
+	if !found {
+		target = p.target(name)
+
+		if parent := p.parent(); parent != nil {
+			link(parent, target)
+		}
+	}
+
+	p.considering = append(p.considering, target)
+}
+
+func (p *makeParser) trace(name, file, line, dueTo string) {
+	target := p.target(name)
+	target.file = file
+	target.line, _ = strconv.ParseInt(line, 10, 64)
+	target.traced = p.last
+	target.hasTraced = true
+
+	if !p.debug {
+		// prerequisites are built first, so any which were remade are known
+		for _, prerequisite := range strings.Fields(dueTo) {
+			if child, found := p.lookup(prerequisite); found && child != target {
+				link(target, child)
+			}
+		}
+	}
+
+	p.running = target
+}
+
+func link(parent, child *makeTarget) {
+	for _, existing := range parent.children {
+		if existing == child {
+			return
+		}
+	}
+
+	parent.children = append(parent.children, child)
+	child.parents = append(child.parents, parent)
+}
+
+// parent is the target whose prerequisites are being considered, or for the
+// first target in a sub-make, the target whose recipe ran the sub-make.
+func (p *makeParser) parent() *makeTarget {
+	if len(p.considering) > 0 {
+		return p.considering[len(p.considering)-1]
+	}
+
+	if len(p.scopes) > 0 {
+		return p.scopes[len(p.scopes)-1].parent
+	}
+
+	return nil
+}
+
+// id qualifies the names of targets in sub-makes with their directory, as
+// each makefile can have its own `all` or `build` target.
+func (p *makeParser) id(name string) (string, string) {
+	if len(p.scopes) == 0 || p.scopes[len(p.scopes)-1].parent == nil || path.IsAbs(name) {
+		return name, ""
+	}
+
+	dir := p.scopes[len(p.scopes)-1].dir
+	return path.Join(dir, name), path.Base(dir)
+}
+
+func (p *makeParser) lookup(name string) (*makeTarget, bool) {
+	id, _ := p.id(name)
+	target, found := p.targets[id]
+	return target, found
+}
+
+func (p *makeParser) target(name string) *makeTarget {
+	id, module := p.id(name)
+	if target, found := p.targets[id]; found {
+		return target
+	}
+
+	target := &makeTarget{
+		id:     id,
+		name:   name,
+		module: module,
+		start:  p.last,
+	}
+
+	if len(p.scopes) > 0 {
+		target.scopeParent = p.scopes[len(p.scopes)-1].parent
+	}
+
+	p.targets[id] = target
+	p.order = append(p.order, target)
+
+	return target
+}
+
+func (p *makeParser) buildProfile() {
+	p.profile.Creator = "GNU make"
+	if p.creator != "" {
+		p.profile.Creator = p.creator
+	}
+	p.profile.TotalCost = p.last.Sub(p.first)
+
+	if !p.debug {
+		p.timeTraced()
+	}
+
+	for _, target := range p.order {
+		if !target.ended {
+			target.end = p.last
+		}
+
+		if len(target.parents) == 0 && target.scopeParent != nil {
+			link(target.scopeParent, target)
+		}
+	}
+
+	starts := map[*makeTarget]time.Time{}
+	inclusive := map[*makeTarget]time.Duration{}
+	for _, target := range p.order {
+		starts[target] = earliestStart(target, map[*makeTarget]bool{})
+		inclusive[target] = target.end.Sub(starts[target])
+	}
+
+	roots := []*makeTarget{}
+	for _, target := range p.order {
+		fn := NewFunction(target.id, target.name)
+		fn.Module = target.module
+		fn.File = target.file
+		fn.LineNumber = target.line
+		fn.Cost = inclusive[target]
+		fn.Timed = true
+		p.profile.addFunction(fn)
+
+		if len(target.parents) == 0 {
+			roots = append(roots, target)
+		}
+	}
+
+	for _, target := range p.order {
+		fn, _ := p.profile.GetFunction(target.id)
+		for _, child := range target.children {
+			p.addCall(fn, child.id, starts[child].Sub(starts[target]), inclusive[child])
+		}
+	}
+
+	// the exporter expects a single root, so several goals, or the loose
+	// targets from --trace, are put under one for the whole make
+	if len(roots) > 1 {
+		root := NewFunction(p.profile.uniqueID("make"), "make")
+		root.Cost = p.profile.TotalCost
+		root.Timed = true
+		p.profile.addFunction(root)
+
+		for _, target := range roots {
+			p.addCall(root, target.id, starts[target].Sub(p.first), inclusive[target])
+		}
+	}
+}
+
+// timeTraced uses the --trace lines for the timings; each recipe is assumed
+// to run until the next starts, which is only true for a serial build.
+func (p *makeParser) timeTraced() {
+	traced := []*makeTarget{}
+	for _, target := range p.order {
+		if target.hasTraced {
+			traced = append(traced, target)
+		}
+	}
+
+	for i, target := range traced {
+		target.start = target.traced
+		target.end = p.last
+		target.ended = true
+
+		if i+1 < len(traced) {
+			target.end = traced[i+1].traced
+		}
+	}
+}
+
+func (p *makeParser) addCall(fn *Function, calleeID string, start, cost time.Duration) {
+	callee, _ := p.profile.GetFunction(calleeID)
+	callee.Called++
+
+	fn.addCall(&Call{
+		CalleeId: calleeID,
+		Calls:    1,
+		Cost:     cost,
+		Start:    start,
+	})
+}
+
+// earliestStart is when a target, or any of its prerequisites, started.
+func earliestStart(target *makeTarget, visited map[*makeTarget]bool) time.Time {
+	start := target.start
+	visited[target] = true
+
+	for _, child := range target.children {
+		if visited[child] {
+			continue
+		}
+
+		if childStart := earliestStart(child, visited); childStart.Before(start) {
+			start = childStart
+		}
+	}
+
+	return start
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseMakeLog(t *testing.T, path string) *Profile {
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	profile, err := NewMakeParser(f).Parse()
+	assert.NoError(t, err)
+
+	return profile
+}
+
+func callIds(fn *Function) []string {
+	ids := []string{}
+	for _, call := range fn.Calls() {
+		ids = append(ids, call.CalleeId)
+	}
+	return ids
+}
+
+func TestParseMakeDebug(t *testing.T) {
+	profile := parseMakeLog(t, "../example/make-debug.log")
+
+	assert.Equal(t, "GNU Make 4.3", profile.Creator)
+	assert.Equal(t, 8*time.Second, profile.TotalCost.Truncate(time.Second))
+
+	// the makefile is considered before the goals, but isn't a target
+	assert.Len(t, profile.functions, 7)
+	assert.Len(t, profile.Roots(), 1)
+
+	build := profile.Roots()[0]
+	assert.Equal(t, "build", build.Name)
+	assert.Equal(t, []string{"one.js", "two.js"}, callIds(build))
+
+	two, found := profile.GetFunction("two.js")
+	assert.True(t, found)
+	assert.Equal(t, 1, two.Called)
+	assert.Equal(t, []string{"two.ts", "three.js"}, callIds(two))
+	assert.Equal(t, 6*time.Second, two.Cost.Truncate(time.Second))
+
+	three, _ := profile.GetFunction("three.js")
+	assert.Equal(t, 5*time.Second, three.Cost.Truncate(time.Second))
+}
+
+func TestParseMakeTrace(t *testing.T) {
+	profile := parseMakeLog(t, "../example/make-trace.log")
+
+	assert.Equal(t, "GNU make", profile.Creator)
+
+	// build has no recipe, so isn't traced, and the targets go under a root
+	// for the whole make
+	root := profile.Roots()[0]
+	assert.Len(t, profile.Roots(), 1)
+	assert.Equal(t, "make", root.Name)
+	assert.Equal(t, []string{"one.js", "two.js"}, callIds(root))
+
+	two, _ := profile.GetFunction("two.js")
+	assert.Equal(t, int64(16), two.LineNumber)
+	assert.Equal(t, "makefile", two.File)
+	assert.Equal(t, []string{"three.js"}, callIds(two))
+	assert.Equal(t, 5*time.Second, two.Cost.Truncate(time.Second))
+}
+
+func TestParseMakeSubMake(t *testing.T) {
+	log := `0.0 Considering target file 'all'.
+0.0  File 'all' does not exist.
+0.0  Finished prerequisites of target file 'all'.
+0.0 Must remake target 'all'.
+0.5 make[1]: Entering directory '/src/lib'
+0.5 Considering target file 'all'.
+0.5  Finished prerequisites of target file 'all'.
+0.5 Must remake target 'all'.
+2.5 Successfully remade target file 'all'.
+2.5 make[1]: Leaving directory '/src/lib'
+3.0 Successfully remade target file 'all'.
+`
+
+	profile, err := NewMakeParser(strings.NewReader(log)).Parse()
+	assert.NoError(t, err)
+
+	all, found := profile.GetFunction("all")
+	assert.True(t, found)
+	assert.Equal(t, 3*time.Second, all.Cost)
+	assert.Equal(t, []string{"/src/lib/all"}, callIds(all))
+
+	lib, _ := profile.GetFunction("/src/lib/all")
+	assert.Equal(t, "all", lib.Name)
+	assert.Equal(t, "lib", lib.Module)
+	assert.Equal(t, 2*time.Second, lib.Cost)
+}
+
+func TestParseMakeTimestamps(t *testing.T) {
+	formats := map[string][]string{
+		"epoch":   {"1665000000.25", "1665000002.75"},
+		"elapsed": {"[00:00:00.250]", "[00:00:02.750]"},
+		"rfc3339": {"2022-10-05T20:00:00.25Z", "2022-10-05T20:00:02.75Z"},
+	}
+
+	for name, stamps := range formats {
+		t.Run(name, func(t *testing.T) {
+			log := stamps[0] + " Considering target file 'x'.\n" +
+				stamps[1] + " Successfully remade target file 'x'.\n"
+
+			profile, err := NewMakeParser(strings.NewReader(log)).Parse()
+			assert.NoError(t, err)
+			assert.Equal(t, 2500*time.Millisecond, profile.TotalCost)
+		})
+	}
+}
+
+func TestParseMakeWithoutTimestamps(t *testing.T) {
+	_, err := NewMakeParser(strings.NewReader("Considering target file 'x'.\n")).Parse()
+	assert.ErrorContains(t, err, "no timestamps")
+}
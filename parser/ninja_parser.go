@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ninjaParser reads the .ninja_log which ninja writes to its build directory.
+// It has when each command started and finished, relative to the start of the
+// build, but not the dependencies between them, so each command is a child of
+// a root for the whole ninja run.
+//
+// ninja appends to the log on every build, so only the last build is used.  A
+// new build is spotted by a command finishing before the one above it.
+type ninjaParser struct {
+	stream *bufio.Scanner
+
+	profile *Profile
+}
+
+type ninjaEdge struct {
+	start   time.Duration
+	end     time.Duration
+	outputs []string
+}
+
+func NewNinjaParser(r io.Reader) *ninjaParser {
+	return &ninjaParser{
+		stream: bufio.NewScanner(r),
+		profile: &Profile{
+			functions: map[string]*Function{},
+		},
+	}
+}
+
+var ninjaHeaderRx = regexp.MustCompile(`^# ninja log v(\d+)$`)
+
+func detectNinja(header []byte) bool {
+	lines := headerLines(header)
+	return len(lines) > 0 && ninjaHeaderRx.MatchString(strings.TrimSpace(lines[0]))
+}
+
+func (p *ninjaParser) Parse() (*Profile, error) {
+	if !p.stream.Scan() {
+		return nil, fmt.Errorf("expected a ninja log header")
+	}
+
+	header := strings.TrimSpace(p.stream.Text())
+	groups := ninjaHeaderRx.FindStringSubmatch(header)
+	if groups == nil {
+		return nil, fmt.Errorf("expected a ninja log header, but found: %s", header)
+	}
+
+	if version, _ := strconv.Atoi(groups[1]); version < 5 {
+		return nil, fmt.Errorf("ninja log v%d is not supported, only v5 and later", version)
+	}
+
+	edges, err := p.parseEdges()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(edges) == 0 {
+		return nil, fmt.Errorf("no commands found in the ninja log")
+	}
+
+	p.profile.Creator = strings.TrimPrefix(header, "# ")
+	p.buildProfile(edges)
+
+	return p.profile, nil
+}
+
+// parseEdges reads the commands of the last build; a command with several
+// outputs has a line for each of them.
+func (p *ninjaParser) parseEdges() ([]*ninjaEdge, error) {
+	edges := map[string]*ninjaEdge{}
+	lastEnd := time.Duration(0)
+	lineNumber := 1
+
+	for p.stream.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(p.stream.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("line %d: expected 5 tab separated fields, but found %d", lineNumber, len(fields))
+		}
+
+		start, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid start: %w", lineNumber, err)
+		}
+
+		end, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid end: %w", lineNumber, err)
+		}
+
+		edge := &ninjaEdge{
+			start: time.Duration(start) * time.Millisecond,
+			end:   time.Duration(end) * time.Millisecond,
+		}
+
+		if edge.end < lastEnd {
+			edges = map[string]*ninjaEdge{}
+		}
+		lastEnd = edge.end
+
+		key := fields[4] + ":" + fields[0]
+		if existing, found := edges[key]; found {
+			edge = existing
+		} else {
+			edges[key] = edge
+		}
+		edge.outputs = append(edge.outputs, fields[3])
+	}
+
+	if err := p.stream.Err(); err != nil {
+		return nil, err
+	}
+
+	sorted := make([]*ninjaEdge, 0, len(edges))
+	for _, edge := range edges {
+		sorted = append(sorted, edge)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].start != sorted[j].start {
+			return sorted[i].start < sorted[j].start
+		}
+		return sorted[i].outputs[0] < sorted[j].outputs[0]
+	})
+
+	return sorted, nil
+}
+
+func (p *ninjaParser) buildProfile(edges []*ninjaEdge) {
+	first := edges[0].start
+	last := time.Duration(0)
+	for _, edge := range edges {
+		if edge.end > last {
+			last = edge.end
+		}
+	}
+
+	p.profile.TotalCost = last - first
+
+	functions := make([]*Function, 0, len(edges))
+	for _, edge := range edges {
+		fn := NewFunction(edge.outputs[0], edge.outputs[0])
+		fn.Cost = edge.end - edge.start
+		fn.Called = 1
+		p.profile.addFunction(fn)
+
+		functions = append(functions, fn)
+	}
+
+	root := NewFunction(p.profile.uniqueID("ninja"), "ninja")
+	root.Cost = p.profile.TotalCost
+	root.Timed = true
+	p.profile.addFunction(root)
+
+	for i, edge := range edges {
+		root.addCall(&Call{
+			CalleeId: functions[i].ID,
+			Calls:    1,
+			Cost:     functions[i].Cost,
+			Start:    edge.start - first,
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNinjaLog(t *testing.T) {
+	f, err := os.Open("../example/.ninja_log")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	profile, err := NewNinjaParser(f).Parse()
+	assert.NoError(t, err)
+
+	assert.Equal(t, "ninja log v5", profile.Creator)
+	assert.Equal(t, 2012*time.Millisecond, profile.TotalCost)
+
+	// only the last, incremental, build is used, and app.map is another
+	// output of the command which built app
+	root := profile.Roots()[0]
+	assert.Equal(t, "ninja", root.Name)
+	assert.True(t, root.Timed)
+	assert.Equal(t, []string{"CMakeFiles/lib.dir/b.cc.o", "liblib.a", "app"}, callIds(root))
+
+	calls := root.Calls()
+	assert.Equal(t, 1327*time.Millisecond, calls[1].Start)
+	assert.Equal(t, 271*time.Millisecond, calls[1].Cost)
+
+	_, found := profile.GetFunction("app.map")
+	assert.False(t, found)
+}
+
+func TestParseNinjaLogParallel(t *testing.T) {
+	log := "# ninja log v6\n" +
+		"0\t1000\t0\ta.o\t1\n" +
+		"0\t1500\t0\tb.o\t2\n" +
+		"1500\t1800\t0\tninja\t3\n"
+
+	profile, err := NewNinjaParser(strings.NewReader(log)).Parse()
+	assert.NoError(t, err)
+
+	// an output called ninja doesn't clash with the root
+	root := profile.Roots()[0]
+	assert.Equal(t, "(ninja)", root.ID)
+	assert.Equal(t, 1800*time.Millisecond, root.CallTime())
+}
+
+func TestParseNinjaLogOldVersion(t *testing.T) {
+	_, err := NewNinjaParser(strings.NewReader("# ninja log v4\n")).Parse()
+	assert.ErrorContains(t, err, "v4 is not supported")
+}
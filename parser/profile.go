@@ -1,6 +1,9 @@
 package parser
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Profile struct {
 	functions map[string]*Function
@@ -20,6 +23,17 @@ func (p *Profile) GetFunction(id string) (*Function, bool) {
 	return f, found
 }
 
+func (p *Profile) Functions() []*Function {
+
+	functions := make([]*Function, 0, len(p.functions))
+
+	for _, fn := range p.functions {
+		functions = append(functions, fn)
+	}
+
+	return functions
+}
+
 func (p *Profile) Roots() []*Function {
 
 	roots := []*Function{}
@@ -32,3 +46,79 @@ func (p *Profile) Roots() []*Function {
 
 	return roots
 }
+
+// uniqueID gives an id for a function the parser adds itself, such as a root
+// for the whole build, which won't clash with a target's id.
+func (p *Profile) uniqueID(id string) string {
+	for {
+		if _, found := p.functions[id]; !found {
+			return id
+		}
+		id = "(" + id + ")"
+	}
+}
+
+// Attach adds another profile under one of this profile's targets, such as
+// the ninja build run by the target's recipe.  The other profile's ids are
+// prefixed with the target's, so they can't clash.  It is assumed to have run
+// as soon as the target's prerequisites were done, so the rest of the recipe
+// still shows as the target's body.
+func (p *Profile) Attach(target string, other *Profile) error {
+	fn, err := p.FindTarget(target)
+	if err != nil {
+		return err
+	}
+
+	roots := other.Roots()
+	if len(roots) != 1 {
+		return fmt.Errorf("expected the profile to attach to have one root target, but it has %d", len(roots))
+	}
+
+	prefix := fn.ID + "/"
+	for _, otherFn := range other.functions {
+		p.addFunction(otherFn.copy(prefix))
+	}
+
+	start := fn.CallTime()
+	if fn.Timed {
+		start = 0
+		for _, call := range fn.Calls() {
+			if end := call.Start + call.Cost; end > start {
+				start = end
+			}
+		}
+	}
+
+	fn.addCall(&Call{
+		CalleeId: prefix + roots[0].ID,
+		Calls:    1,
+		Cost:     other.TotalCost,
+		Start:    start,
+	})
+	p.functions[prefix+roots[0].ID].Called = 1
+
+	return nil
+}
+
+// FindTarget looks up a target by its id, or failing that, its name.
+func (p *Profile) FindTarget(target string) (*Function, error) {
+	if fn, found := p.functions[target]; found {
+		return fn, nil
+	}
+
+	matches := []*Function{}
+	for _, fn := range p.functions {
+		if fn.Name == target {
+			matches = append(matches, fn)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no target called %q", target)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("several targets are called %q, use its id instead", target)
+	}
+}
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttach(t *testing.T) {
+	f, err := os.Open("../example/callgrind.out.build-3")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	profile, err := NewCallgrindParser(f).Parse()
+	assert.NoError(t, err)
+
+	ninja, err := os.Open("../example/.ninja_log")
+	assert.NoError(t, err)
+	defer ninja.Close()
+
+	other, err := NewNinjaParser(ninja).Parse()
+	assert.NoError(t, err)
+
+	assert.NoError(t, profile.Attach("three.js", other))
+
+	three, _ := profile.GetFunction("three.js")
+	assert.Equal(t, []string{"three.ts", "three.js/ninja"}, callIds(three))
+	assert.Equal(t, 100*time.Microsecond, three.Calls()[1].Start)
+
+	root, found := profile.GetFunction("three.js/ninja")
+	assert.True(t, found)
+	assert.Equal(t, 1, root.Called)
+	assert.Contains(t, callIds(root), "three.js/app")
+
+	assert.Len(t, profile.Roots(), 1)
+}
+
+func TestAttachUnknownTarget(t *testing.T) {
+	profile := &Profile{functions: map[string]*Function{}}
+
+	err := profile.Attach("missing", &Profile{functions: map[string]*Function{}})
+	assert.ErrorContains(t, err, `no target called "missing"`)
+}
+
+func TestCallTime(t *testing.T) {
+	fn := NewFunction("build", "build")
+	fn.addCall(&Call{CalleeId: "a", Cost: 2 * time.Second})
+	fn.addCall(&Call{CalleeId: "b", Cost: 3 * time.Second, Start: time.Second})
+	fn.addCall(&Call{CalleeId: "c", Cost: time.Second, Start: 5 * time.Second})
+
+	assert.Equal(t, 6*time.Second, fn.CallTime())
+
+	// a and b overlap, and there is a gap before c
+	fn.Timed = true
+	assert.Equal(t, 5*time.Second, fn.CallTime())
+}
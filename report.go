@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"makeotel/layout"
+	"makeotel/parser"
+	"makeotel/report"
+	"makeotel/tracing"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type reportConfig struct {
+	html string
+	top  int
+}
+
+func reportFlags(defaults settings, conf *reportConfig) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("report", pflag.ContinueOnError)
+	flags.StringVar(&conf.html, "html", defaults.String("html", "-"), "where to write the HTML report, - for stdout")
+	flags.IntVar(&conf.top, "top", int(defaults.Int64("report.top", 10)), "how many of the slowest targets to list.  0 lists them all")
+
+	return flags
+}
+
+func reportCommand() *command {
+	reportConf := &reportConfig{}
+
+	return &command{
+		name:    "report",
+		summary: "Write an HTML report of profiles",
+		usage:   "makeotel report --html <path> [flags] <path_to_profile>...",
+		description: `Writes a single HTML page for the profiles, which needs nothing else to view
+it, so it can be published as a CI artifact.  Each profile has its slowest
+targets, its critical path, its targets as a collapsible tree, and a timeline.`,
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			input := inputFlags(defaults, conf)
+			input.MarkHidden("single-trace")
+
+			return []flagGroup{
+				{"Report Flags", reportFlags(defaults, reportConf)},
+				{"Input Flags", input},
+				{"Trace Flags", layoutTraceFlags(defaults, conf)},
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: func(conf *config, otelConf *tracing.Config, args []string) error {
+			return runReport(conf, reportConf, args)
+		},
+	}
+}
+
+func runReport(conf *config, reportConf *reportConfig, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("report takes at least one argument: path")
+	}
+
+	if err := parser.CheckFormat(conf.inputFormat); err != nil {
+		return err
+	}
+
+	paths, err := expandPaths(args)
+	if err != nil {
+		return err
+	}
+
+	files, failed := loadProfiles(paths, conf.inputFormat, time.Unix(conf.timestamp, 0))
+
+	if err := attachProfiles(profilesOf(files), conf.attach); err != nil {
+		return err
+	}
+
+	if len(files) > 0 {
+		reports := make([]report.Report, 0, len(files))
+		names := make([]string, 0, len(files))
+		for _, file := range files {
+			reports = append(reports, report.Report{
+				Name:    file.path,
+				Profile: file.profile,
+				Root:    layout.Build(file.profile, file.start, conf.layoutOptions()),
+			})
+			names = append(names, filepath.Base(file.path))
+		}
+
+		w, closer, err := createOutput(reportConf.html)
+		if err != nil {
+			return err
+		}
+
+		err = report.WriteHTML(w, "Build report: "+strings.Join(names, ", "), reports, reportConf.top)
+		if closeErr := closer(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profiles could not be reported", failed, len(paths))
+	}
+
+	return nil
+}
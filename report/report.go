@@ -0,0 +1,164 @@
+package report
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"makeotel/analysis"
+	"makeotel/layout"
+	"makeotel/parser"
+	"time"
+)
+
+// Report is one profile to include in the page, with its targets laid out
+// on the timeline.
+type Report struct {
+	Name    string
+	Profile *parser.Profile
+	Root    *layout.Span
+}
+
+type page struct {
+	Title     string
+	Generated time.Time
+	Sections  []*section
+}
+
+type section struct {
+	Name     string
+	Creator  string
+	Command  string
+	Start    time.Time
+	Duration time.Duration
+
+	Tree     []*treeNode
+	Timeline []*timelineRow
+	Critical []*layout.Span
+	Slowest  []*analysis.Target
+}
+
+type treeNode struct {
+	*analysis.Node
+	Critical bool
+	Open     bool
+	Children []*treeNode
+}
+
+type timelineRow struct {
+	Name     string
+	Depth    int
+	Left     float64
+	Width    float64
+	Duration time.Duration
+	Body     bool
+	Critical bool
+}
+
+//go:embed report.html
+var reportTemplate string
+
+var tmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration": formatDuration,
+	"indent":   func(depth int) int { return depth * 16 },
+}).Parse(reportTemplate))
+
+// WriteHTML writes a single page, which needs nothing else to view it, with
+// a section for each report: the slowest targets, the critical path, the
+// targets as a collapsible tree, and a timeline.  top is how many of the
+// slowest targets to list.
+func WriteHTML(w io.Writer, title string, reports []Report, top int) error {
+	p := &page{
+		Title:     title,
+		Generated: time.Now(),
+	}
+
+	for _, report := range reports {
+		p.Sections = append(p.Sections, newSection(report, top))
+	}
+
+	return tmpl.Execute(w, p)
+}
+
+func newSection(report Report, top int) *section {
+	root := report.Root
+	critical := root.CriticalPath()
+
+	s := &section{
+		Name:     report.Name,
+		Creator:  report.Profile.Creator,
+		Command:  report.Profile.Command,
+		Start:    root.Start,
+		Duration: root.End.Sub(root.Start),
+		Critical: critical,
+	}
+
+	targets := analysis.Targets(report.Profile)
+	analysis.SortTargets(targets, analysis.SortSelf)
+	if top > 0 && len(targets) > top {
+		targets = targets[:top]
+	}
+	s.Slowest = targets
+
+	nodes := analysis.Tree(report.Profile)
+	analysis.SortTree(nodes, analysis.SortInclusive)
+	s.Tree = treeNodes(nodes, critical, 0, true)
+
+	// the spans are laid out relative to the root, which fills the timeline
+	onPath := map[*layout.Span]bool{}
+	for _, span := range critical {
+		onPath[span] = true
+	}
+
+	root.Walk(func(span *layout.Span, depth int) {
+		row := &timelineRow{
+			Name:     span.Name,
+			Depth:    depth,
+			Duration: span.End.Sub(span.Start),
+			Body:     span.Body,
+			Critical: onPath[span],
+		}
+
+		if s.Duration > 0 {
+			row.Left = float64(span.Start.Sub(root.Start)) / float64(s.Duration) * 100
+			row.Width = float64(row.Duration) / float64(s.Duration) * 100
+		}
+
+		s.Timeline = append(s.Timeline, row)
+	})
+
+	return s
+}
+
+// treeNodes marks the nodes on the critical path; as a target can appear
+// under several others, a node is only on it when its parent is too.
+func treeNodes(nodes []*analysis.Node, critical []*layout.Span, depth int, parentCritical bool) []*treeNode {
+	result := make([]*treeNode, 0, len(nodes))
+
+	for _, node := range nodes {
+		onPath := parentCritical && depth < len(critical) && critical[depth].ID == node.ID
+
+		result = append(result, &treeNode{
+			Node:     node,
+			Critical: onPath,
+			Open:     depth < 1 || onPath,
+			Children: treeNodes(node.Children, critical, depth+1, onPath),
+		})
+	}
+
+	return result
+}
+
+// formatDuration rounds to a precision which suits the size of the duration,
+// as build times range from microseconds to hours.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second).String()
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	default:
+		return d.String()
+	}
+}
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"bytes"
+	"makeotel/layout"
+	"makeotel/parser"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadReport(t *testing.T, path string) Report {
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	p, err := parser.NewParser(f, parser.FormatAuto)
+	assert.NoError(t, err)
+
+	profile, err := p.Parse()
+	assert.NoError(t, err)
+
+	return Report{
+		Name:    path,
+		Profile: profile,
+		Root:    layout.Build(profile, time.Unix(1_600_000_000, 0), layout.Options{BodySuffix: "_body"}),
+	}
+}
+
+func TestNewSection(t *testing.T) {
+	s := newSection(loadReport(t, "../example/make-trace.log"), 2)
+
+	assert.Equal(t, 7999951*time.Microsecond, s.Duration)
+
+	assert.Len(t, s.Slowest, 2)
+	assert.Equal(t, "three.js", s.Slowest[0].Name)
+	assert.Equal(t, "one.js", s.Slowest[1].Name)
+
+	// two.js is on the critical path, and is the slowest, so is shown first
+	root := s.Tree[0]
+	assert.True(t, root.Critical)
+	assert.Equal(t, "two.js", root.Children[0].Name)
+	assert.True(t, root.Children[0].Critical)
+	assert.True(t, root.Children[0].Children[0].Critical)
+	assert.Equal(t, "one.js", root.Children[1].Name)
+	assert.False(t, root.Children[1].Critical)
+
+	// one.js is built first, and two.js starts when it finishes
+	rows := map[string]*timelineRow{}
+	for _, row := range s.Timeline {
+		rows[row.Name] = row
+	}
+	assert.Equal(t, 0.0, rows["one.js"].Left)
+	assert.InDelta(t, rows["one.js"].Width, rows["two.js"].Left, 0.001)
+	assert.True(t, rows["two.js_body"].Body)
+	assert.False(t, rows["two.js_body"].Critical)
+}
+
+func TestWriteHTML(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := WriteHTML(out, "Build <report>", []Report{loadReport(t, "../example/make-trace.log")}, 10)
+	assert.NoError(t, err)
+
+	html := out.String()
+	assert.True(t, strings.HasPrefix(html, "<!DOCTYPE html>"))
+	assert.Contains(t, html, "<title>Build &lt;report&gt;</title>")
+	assert.Contains(t, html, `<summary><span class="critical">three.js</span>`)
+	assert.Contains(t, html, `<li>three.js <span class="num meta">5.002s</span></li>`)
+	assert.NotContains(t, html, "<script")
+}
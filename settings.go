@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"makeotel/tracing"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const SettingsFileName = ".makeotel.yaml"
+
+// fileSettings holds the values from the configuration files, keyed by flag
+// name.  They are loaded before the flags are built, as they become the flag
+// defaults; this gives the precedence of flags, then env vars, then the
+// files, then the built in defaults.
+var fileSettings = settings{}
+
+// commandSettings are the flags which take different values in each command,
+// such as --sort, so they are only read from a section named after the
+// command, as in "stats: {sort: p99}".  They are looked up with the command
+// as a prefix, such as "stats.sort".
+var commandSettings = []string{"output-format", "sort", "top"}
+
+// flagOnlySettings only make sense for one run, so are not read from the
+// files.
+var flagOnlySettings = []string{"config", "help", "version"}
+
+type settings map[string]interface{}
+
+// loadSettings reads the file given by --config, or otherwise merges the
+// file in the home directory with the one in the project, with the project
+// file taking precedence.
+func loadSettings(args []string) error {
+	fileSettings = settings{}
+
+	if path := configFlagValue(args); path != "" {
+		return fileSettings.merge(path)
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		if err := fileSettings.mergeIfExists(filepath.Join(home, SettingsFileName)); err != nil {
+			return err
+		}
+	}
+
+	if path := findProjectSettings(); path != "" {
+		if err := fileSettings.merge(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// configFlagValue finds --config before the flags are parsed, as its value is
+// needed to build the flags.
+func configFlagValue(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		if strings.HasPrefix(arg, "--config=") {
+			return strings.TrimPrefix(arg, "--config=")
+		}
+
+		if arg == "--config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+
+	return ""
+}
+
+// findProjectSettings looks in the current directory and its parents, stopping
+// at the root of the repository.
+func findProjectSettings() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, SettingsFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func (s settings) mergeIfExists(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	return s.merge(path)
+}
+
+func (s settings) merge(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for k, v := range values {
+		// a command's section is merged with the one from the other file,
+		// rather than replacing it
+		section, isSection := v.(map[string]interface{})
+		existing, hasExisting := s[k].(map[string]interface{})
+		if isSection && hasExisting && findCommand(k) != nil {
+			for name, val := range section {
+				existing[name] = val
+			}
+			continue
+		}
+
+		s[k] = v
+	}
+
+	return nil
+}
+
+// validate reports any keys in the files which are not flags, and any values
+// which the flags would not accept, so that a typo doesn't silently get
+// ignored.  A command's section may only have the commandSettings which the
+// command has.
+func (s settings) validate(flags *pflag.FlagSet) error {
+	unknown := []string{}
+	invalid := []string{}
+	for key, val := range s {
+		if contains(flagOnlySettings, key) {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		if contains(commandSettings, key) {
+			return fmt.Errorf("%s in %s depends on the command, so it must be set in the command's section, such as stats: {%s: ...}", key, SettingsFileName, key)
+		}
+
+		if cmd := findCommand(key); cmd != nil {
+			section, ok := val.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s in %s must be a section of settings for the %s command", key, SettingsFileName, key)
+			}
+
+			cmdFlags := cmd.flagSet(settings{}, &config{}, &tracing.Config{})
+			for name, val := range section {
+				if !contains(commandSettings, name) || cmdFlags.Lookup(name) == nil {
+					unknown = append(unknown, key+"."+name)
+				} else if err := checkSetting(cmdFlags.Lookup(name), val); err != nil {
+					invalid = append(invalid, key+"."+name+": "+err.Error())
+				}
+			}
+			continue
+		}
+
+		if flag := flags.Lookup(key); flag == nil {
+			unknown = append(unknown, key)
+		} else if err := checkSetting(flag, val); err != nil {
+			invalid = append(invalid, key+": "+err.Error())
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown settings in %s: %s", SettingsFileName, strings.Join(unknown, ", "))
+	}
+
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return fmt.Errorf("invalid settings in %s: %s", SettingsFileName, strings.Join(invalid, "; "))
+	}
+
+	return nil
+}
+
+// checkSetting parses the value as the flag's type, as otherwise a value which
+// can't be parsed, such as "settle: 5", leaves the flag with its built in
+// default.
+func checkSetting(flag *pflag.Flag, val interface{}) error {
+	var err error
+	switch flag.Value.Type() {
+	case "bool":
+		_, err = strconv.ParseBool(fmt.Sprint(val))
+	case "int", "int64":
+		_, err = strconv.ParseInt(fmt.Sprint(val), 10, 64)
+	case "duration":
+		_, err = time.ParseDuration(fmt.Sprint(val))
+	}
+
+	return err
+}
+
+// lookup finds a setting by its flag name, or for one of the
+// commandSettings, by the command and the flag name, such as "stats.sort".
+func (s settings) lookup(name string) (interface{}, bool) {
+	if cmd, key, found := strings.Cut(name, "."); found {
+		section, ok := s[cmd].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		val, found := section[key]
+		return val, found
+	}
+
+	val, found := s[name]
+	return val, found
+}
+
+func (s settings) String(name string, fallback string, envVars ...string) string {
+	for _, env := range envVars {
+		if val := os.Getenv(env); val != "" {
+			return val
+		}
+	}
+
+	if val, found := s.lookup(name); found {
+		return fmt.Sprint(val)
+	}
+
+	return fallback
+}
+
+func (s settings) Bool(name string, fallback bool, envVars ...string) bool {
+	for _, env := range envVars {
+		if val, err := strconv.ParseBool(os.Getenv(env)); err == nil {
+			return val
+		}
+	}
+
+	if val, found := s.lookup(name); found {
+		if b, err := strconv.ParseBool(fmt.Sprint(val)); err == nil {
+			return b
+		}
+	}
+
+	return fallback
+}
+
+func (s settings) Int64(name string, fallback int64) int64 {
+	if val, found := s.lookup(name); found {
+		if i, err := strconv.ParseInt(fmt.Sprint(val), 10, 64); err == nil {
+			return i
+		}
+	}
+
+	return fallback
+}
+
+func (s settings) Duration(name string, fallback time.Duration) time.Duration {
+	if val, found := s.lookup(name); found {
+		if d, err := time.ParseDuration(fmt.Sprint(val)); err == nil {
+			return d
+		}
+	}
+
+	return fallback
+}
+
+// Pairs gives the k=v pairs for a flag, which can be written in the
+// file as either a list of k=v strings, or a map.  Pairs from the env var (in
+// the OTEL_RESOURCE_ATTRIBUTES k=v,k=v format) override the ones in the file.
+func (s settings) Pairs(name string, envVars ...string) []string {
+	pairs := map[string]string{}
+
+	val, _ := s.lookup(name)
+	switch val := val.(type) {
+	case []interface{}:
+		for _, item := range val {
+			k, v, _ := strings.Cut(fmt.Sprint(item), "=")
+			pairs[k] = v
+		}
+	case map[string]interface{}:
+		for k, v := range val {
+			pairs[k] = fmt.Sprint(v)
+		}
+	}
+
+	for _, env := range envVars {
+		for _, item := range strings.Split(os.Getenv(env), ",") {
+			if k, v, found := strings.Cut(item, "="); found {
+				if unescaped, err := url.QueryUnescape(v); err == nil {
+					v = unescaped
+				}
+				pairs[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+	}
+
+	result := make([]string, 0, len(pairs))
+	for k, v := range pairs {
+		result = append(result, k+"="+v)
+	}
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"makeotel/tracing"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSettings(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), SettingsFileName)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestSettingsPrecedence(t *testing.T) {
+	path := writeSettings(t, `
+exporter: console
+otlp-endpoint: file:4317
+otlp-insecure: true
+resource-attributes:
+  repo: file-repo
+  team: file-team
+`)
+	t.Setenv(OtlpEndpointEnvVar, "env:4317")
+	t.Setenv(OtlpTracesEndpointEnvVar, "")
+	t.Setenv(OtelTracesExporterEnvVar, "")
+	t.Setenv(OtlpInsecureEnvVar, "")
+	t.Setenv(OtelResourceAttributesEnvVar, "team=env-team")
+
+	args := []string{"--config", path, "--exporter", "none"}
+	assert.NoError(t, loadSettings(args))
+
+	conf := &tracing.Config{}
+	flags := otelFlags(fileSettings, conf)
+	flags.AddFlagSet(commandFlags(&config{}))
+	assert.NoError(t, fileSettings.validate(flags))
+	assert.NoError(t, flags.Parse(args))
+
+	assert.Equal(t, tracing.ExporterNone, conf.Exporter)
+	assert.Equal(t, "env:4317", conf.Endpoint)
+	assert.True(t, conf.Insecure)
+	assert.Equal(t, []string{"repo=file-repo", "team=env-team"}, conf.ResourceAttributesRaw)
+	assert.Equal(t, tracing.ProtocolGrpc, conf.Protocol)
+}
+
+func TestSettingsUnknownKey(t *testing.T) {
+	path := writeSettings(t, "otlp-endpiont: localhost:4317\n")
+
+	assert.NoError(t, loadSettings([]string{"--config=" + path}))
+	assert.Error(t, fileSettings.validate(otelFlags(fileSettings, &tracing.Config{})))
+}
+
+func TestConfigFlagValue(t *testing.T) {
+	assert.Equal(t, "a.yaml", configFlagValue([]string{"--config", "a.yaml", "file"}))
+	assert.Equal(t, "b.yaml", configFlagValue([]string{"--config=b.yaml"}))
+	assert.Equal(t, "", configFlagValue([]string{"exec", "--", "make", "--config", "c.yaml"}))
+	assert.Equal(t, "", configFlagValue([]string{"file"}))
+}
+
+func TestSettingsCommandSections(t *testing.T) {
+	path := writeSettings(t, `
+exporter: none
+stats:
+  sort: p99
+  output-format: csv
+inspect:
+  sort: self
+  top: 5
+`)
+	t.Cleanup(func() { fileSettings = settings{} })
+	assert.NoError(t, loadSettings([]string{"--config", path}))
+	assert.NoError(t, fileSettings.validate(everyFlag()))
+
+	statsConf := &statsConfig{}
+	assert.NoError(t, statsFlags(fileSettings, statsConf).Parse(nil))
+	assert.Equal(t, "p99", statsConf.sort)
+	assert.Equal(t, OutputCsv, statsConf.format)
+
+	inspectConf := &inspectConfig{}
+	assert.NoError(t, inspectFlags(fileSettings, inspectConf).Parse(nil))
+	assert.Equal(t, "self", inspectConf.sort)
+	assert.Equal(t, 5, inspectConf.top)
+
+	// the other commands keep their own defaults
+	output := &outputConfig{}
+	assert.NoError(t, outputFlags(fileSettings, "send", output).Parse(nil))
+	assert.Equal(t, OutputText, output.format)
+
+	reportConf := &reportConfig{}
+	assert.NoError(t, reportFlags(fileSettings, reportConf).Parse(nil))
+	assert.Equal(t, 10, reportConf.top)
+}
+
+func TestSettingsCommandSectionErrors(t *testing.T) {
+	t.Cleanup(func() { fileSettings = settings{} })
+
+	for _, content := range []string{
+		"output-format: csv\n",
+		"sort: self\n",
+		"stats: p99\n",
+		"stats:\n  exporter: none\n",
+		"diff:\n  sort: self\n",
+		"help: true\n",
+		"version: true\n",
+	} {
+		path := writeSettings(t, content)
+		assert.NoError(t, loadSettings([]string{"--config", path}))
+		assert.Error(t, fileSettings.validate(everyFlag()), content)
+	}
+}
+
+func TestSettingsInvalidValues(t *testing.T) {
+	t.Cleanup(func() { fileSettings = settings{} })
+
+	for _, c := range []struct {
+		content string
+		err     string
+	}{
+		{"settle: 5\n", "settle: time: missing unit in duration"},
+		{"otlp-insecure: yse\n", "otlp-insecure: strconv.ParseBool"},
+		{"report:\n  top: ten\n", "report.top: strconv.ParseInt"},
+	} {
+		path := writeSettings(t, c.content)
+		assert.NoError(t, loadSettings([]string{"--config", path}))
+		assert.ErrorContains(t, fileSettings.validate(everyFlag()), c.err, c.content)
+	}
+
+	path := writeSettings(t, "settle: 5s\notlp-insecure: true\nresource-attributes: {team: build}\nreport:\n  top: 3\n")
+	assert.NoError(t, loadSettings([]string{"--config", path}))
+	assert.NoError(t, fileSettings.validate(everyFlag()))
+}
+
+func TestHelpShowsBuiltInDefaults(t *testing.T) {
+	t.Cleanup(func() { fileSettings = settings{} })
+
+	path := writeSettings(t, "settle: 9s\n")
+	assert.NoError(t, loadSettings([]string{"--config", path}))
+
+	stdout, err := captureStdout(t, findCommand("watch").helpText)
+	assert.NoError(t, err)
+	assert.Contains(t, stdout, "(default 2s)")
+	assert.NotContains(t, stdout, "9s")
+
+	// the settings are still used after the help is built
+	watchConf := &watchConfig{}
+	assert.NoError(t, watchFlags(fileSettings, watchConf).Parse(nil))
+	assert.Equal(t, 9*time.Second, watchConf.settle)
+}
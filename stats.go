@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"makeotel/analysis"
+	"makeotel/parser"
+	"makeotel/tracing"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const OutputCsv = "csv"
+
+const (
+	OrderArgs = "args"
+	OrderName = "name"
+	OrderTime = "time"
+)
+
+var orders = []string{OrderArgs, OrderName, OrderTime}
+
+type statsConfig struct {
+	time    string
+	sort    string
+	top     int
+	format  string
+	order   string
+	metrics bool
+}
+
+func statsFlags(defaults settings, conf *statsConfig) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("stats", pflag.ContinueOnError)
+	flags.StringVar(&conf.time, "time", defaults.String("time", analysis.TimeSelf), "which time of each target to use: self, or inclusive (adding the time of its prerequisites)")
+	flags.StringVar(&conf.sort, "sort", defaults.String("stats.sort", analysis.StatP90), "the column to sort by: "+strings.Join(analysis.StatColumns, ", "))
+	flags.IntVar(&conf.top, "top", int(defaults.Int64("stats.top", 0)), "only show the first N targets.  0 shows them all")
+	flags.StringVar(&conf.format, "output-format", defaults.String("stats.output-format", OutputText), "how to show the stats: text (a table), csv, or json")
+	flags.StringVar(&conf.order, "order", defaults.String("order", OrderArgs), "the order the builds ran in, for the trend: args (as given, with globs sorted by name), name (of the file), or time (when the file was written, less the build's time)")
+	flags.BoolVar(&conf.metrics, "metrics", defaults.Bool("metrics", false), "also send the stats as OpenTelemetry metrics, using the exporter flags")
+
+	return flags
+}
+
+func statsCommand() *command {
+	statsConf := &statsConfig{}
+
+	return &command{
+		name:    "stats",
+		summary: "Summarise the target times across many profiles",
+		usage:   "makeotel stats [flags] <path_to_profile>...",
+		description: `Summarises each target's time across many profiles, such as from nightly
+builds, matching the targets by their id.  Shows how many profiles have each
+target, the mean, p50, p90, p99 and max of its time, and its trend: how much
+its time changes from one build to the next, with the builds in the order
+given by --order.  A target whose p99 or max is far above its p50 is slow only some
+of the time.`,
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			input := inputFlags(defaults, conf)
+			input.MarkHidden("single-trace")
+
+			return []flagGroup{
+				{"Stats Flags", statsFlags(defaults, statsConf)},
+				{"Input Flags", input},
+				{"OpenTelemetry Flags", otelFlags(defaults, otelConf)},
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: func(conf *config, otelConf *tracing.Config, args []string) error {
+			return runStats(conf, otelConf, statsConf, args, os.Stdout)
+		},
+	}
+}
+
+func runStats(conf *config, otelConf *tracing.Config, statsConf *statsConfig, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("stats takes at least one argument: path")
+	}
+
+	if !contains(analysis.TimeColumns, statsConf.time) {
+		return fmt.Errorf("unknown time %q, expected one of %s", statsConf.time, strings.Join(analysis.TimeColumns, ", "))
+	}
+
+	if !contains(analysis.StatColumns, statsConf.sort) {
+		return fmt.Errorf("unknown sort column %q, expected one of %s", statsConf.sort, strings.Join(analysis.StatColumns, ", "))
+	}
+
+	formats := []string{OutputText, OutputCsv, OutputJson}
+	if !contains(formats, statsConf.format) {
+		return fmt.Errorf("unknown output format %q, expected one of %s", statsConf.format, strings.Join(formats, ", "))
+	}
+
+	if !contains(orders, statsConf.order) {
+		return fmt.Errorf("unknown order %q, expected one of %s", statsConf.order, strings.Join(orders, ", "))
+	}
+
+	if err := parser.CheckFormat(conf.inputFormat); err != nil {
+		return err
+	}
+
+	if statsConf.metrics {
+		if err := otelConf.ParseHeaders(); err != nil {
+			return err
+		}
+
+		if err := otelConf.ParseResourceAttributes(); err != nil {
+			return err
+		}
+	}
+
+	paths, err := expandPaths(args)
+	if err != nil {
+		return err
+	}
+
+	files, failed := loadProfiles(paths, conf.inputFormat, time.Unix(conf.timestamp, 0))
+	orderProfiles(files, paths, statsConf.order)
+
+	if err := attachProfiles(profilesOf(files), conf.attach); err != nil {
+		return err
+	}
+
+	if len(files) > 0 {
+		stats := analysis.Summarise(profilesOf(files), statsConf.time)
+		analysis.SortStats(stats, statsConf.sort)
+		stats = limit(stats, statsConf.top)
+
+		if err := writeStats(w, statsConf.format, stats); err != nil {
+			return err
+		}
+
+		if statsConf.metrics {
+			if err := tracing.SendMetrics(context.Background(), otelConf, statsGauges(stats, statsConf.time), time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profiles could not be read", failed, len(paths))
+	}
+
+	return nil
+}
+
+// orderProfiles puts the profiles in the order their builds ran, which the
+// trend relies on.  loadProfiles has already ordered them by time, but the
+// files' times are no use for profiles which were all downloaded at once,
+// such as from CI.
+func orderProfiles(files []*profileFile, paths []string, order string) {
+	position := map[string]int{}
+	for i, path := range paths {
+		if _, found := position[path]; !found {
+			position[path] = i
+		}
+	}
+
+	switch order {
+	case OrderArgs:
+		sort.SliceStable(files, func(i, j int) bool {
+			return position[files[i].path] < position[files[j].path]
+		})
+	case OrderName:
+		sort.SliceStable(files, func(i, j int) bool {
+			return filepath.Base(files[i].path) < filepath.Base(files[j].path)
+		})
+	}
+}
+
+var statsHeader = []string{"target", "id", "count", "mean_ms", "p50_ms", "p90_ms", "p99_ms", "max_ms", "trend_ms"}
+
+func writeStats(w io.Writer, format string, stats []*analysis.Stats) error {
+	switch format {
+	case OutputCsv:
+		out := csv.NewWriter(w)
+		out.Write(statsHeader)
+
+		for _, s := range stats {
+			out.Write([]string{
+				s.Name,
+				s.ID,
+				strconv.Itoa(s.Count),
+				formatMilliseconds(s.Mean),
+				formatMilliseconds(s.P50),
+				formatMilliseconds(s.P90),
+				formatMilliseconds(s.P99),
+				formatMilliseconds(s.Max),
+				formatMilliseconds(s.Trend),
+			})
+		}
+
+		out.Flush()
+		return out.Error()
+
+	case OutputJson:
+		result := make([]*statsResult, 0, len(stats))
+		for _, s := range stats {
+			result = append(result, &statsResult{
+				Name:  s.Name,
+				ID:    s.ID,
+				Count: s.Count,
+				Mean:  milliseconds(s.Mean),
+				P50:   milliseconds(s.P50),
+				P90:   milliseconds(s.P90),
+				P99:   milliseconds(s.P99),
+				Max:   milliseconds(s.Max),
+				Trend: milliseconds(s.Trend),
+			})
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TARGET\tCOUNT\tMEAN\tP50\tP90\tP99\tMAX\tTREND")
+
+		for _, s := range stats {
+			trend := s.Trend.String()
+			if s.Trend > 0 {
+				trend = "+" + trend
+			}
+
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Count, s.Mean, s.P50, s.P90, s.P99, s.Max, trend)
+		}
+
+		return tw.Flush()
+	}
+}
+
+type statsResult struct {
+	Name  string  `json:"target"`
+	ID    string  `json:"id"`
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+	Trend float64 `json:"trend_ms"`
+}
+
+func formatMilliseconds(d time.Duration) string {
+	return strconv.FormatFloat(milliseconds(d), 'f', -1, 64)
+}
+
+// statsGauges gives a metric for each statistic, with the target and the
+// statistic as attributes, so they can be graphed together.
+func statsGauges(stats []*analysis.Stats, column string) []tracing.Gauge {
+	gauges := []tracing.Gauge{}
+
+	for _, s := range stats {
+		target := []attribute.KeyValue{
+			attribute.String("target.id", s.ID),
+			attribute.String("target.name", s.Name),
+			attribute.String("time", column),
+		}
+
+		gauges = append(gauges, tracing.Gauge{
+			Name:        "makeotel.target.count",
+			Description: "how many of the profiles have the target",
+			Unit:        "{profile}",
+			Attributes:  target,
+			Value:       float64(s.Count),
+		})
+
+		for _, stat := range []struct {
+			name  string
+			value time.Duration
+		}{
+			{analysis.StatMean, s.Mean},
+			{analysis.StatP50, s.P50},
+			{analysis.StatP90, s.P90},
+			{analysis.StatP99, s.P99},
+			{analysis.StatMax, s.Max},
+		} {
+			gauges = append(gauges, tracing.Gauge{
+				Name:        "makeotel.target.duration",
+				Description: "a statistic of the target's time across the profiles",
+				Unit:        "s",
+				Attributes:  append([]attribute.KeyValue{attribute.String("statistic", stat.name)}, target...),
+				Value:       stat.value.Seconds(),
+			})
+		}
+
+		gauges = append(gauges, tracing.Gauge{
+			Name:        "makeotel.target.trend",
+			Description: "how much the target's time changes from one profile to the next",
+			Unit:        "s",
+			Attributes:  target,
+			Value:       s.Trend.Seconds(),
+		})
+	}
+
+	return gauges
+}
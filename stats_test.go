@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"makeotel/analysis"
+	"makeotel/parser"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func testStatsConfig() *statsConfig {
+	return &statsConfig{time: analysis.TimeSelf, sort: analysis.StatMax, format: OutputText, order: OrderArgs}
+}
+
+func TestStatsCsv(t *testing.T) {
+	conf := &config{inputFormat: parser.FormatAuto}
+	statsConf := testStatsConfig()
+	statsConf.format = OutputCsv
+
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, runStats(conf, nil, statsConf, diffPaths, buffer))
+	assert.Equal(t, `target,id,count,mean_ms,p50_ms,p90_ms,p99_ms,max_ms,trend_ms
+one.js,one.js,2,3002,3001.6,3002.4,3002.4,3002.4,-0.8
+two.js,two.js,1,1002.1,1002.1,1002.1,1002.1,1002.1,0
+build,build,2,0.35,0.3,0.4,0.4,0.4,-0.1
+one.ts,one.ts,2,0.1,0.1,0.1,0.1,0.1,0
+two.ts,two.ts,1,0.1,0.1,0.1,0.1,0.1,0
+`, buffer.String())
+
+	// the trend follows --order, so reversing the builds reverses it
+	buffer.Reset()
+	assert.NoError(t, runStats(conf, nil, statsConf, []string{diffPaths[1], diffPaths[0]}, buffer))
+	assert.Contains(t, buffer.String(), "one.js,one.js,2,3002,3001.6,3002.4,3002.4,3002.4,0.8\n")
+
+	statsConf.order = OrderName
+	buffer.Reset()
+	assert.NoError(t, runStats(conf, nil, statsConf, []string{diffPaths[1], diffPaths[0]}, buffer))
+	assert.Contains(t, buffer.String(), "one.js,one.js,2,3002,3001.6,3002.4,3002.4,3002.4,-0.8\n")
+}
+
+func TestStatsJson(t *testing.T) {
+	statsConf := testStatsConfig()
+	statsConf.time = analysis.TimeInclusive
+	statsConf.format = OutputJson
+	statsConf.top = 1
+
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, runStats(&config{inputFormat: parser.FormatAuto}, nil, statsConf, diffPaths, buffer))
+
+	result := []*statsResult{}
+	assert.NoError(t, json.Unmarshal(buffer.Bytes(), &result))
+	assert.Equal(t, []*statsResult{{
+		Name:  "build",
+		ID:    "build",
+		Count: 2,
+		Mean:  3503.55,
+		P50:   3002.9,
+		P90:   4004.2,
+		P99:   4004.2,
+		Max:   4004.2,
+		Trend: 1001.3,
+	}}, result)
+}
+
+func TestStatsValidation(t *testing.T) {
+	cases := []struct {
+		name   string
+		update func(*statsConfig)
+		err    string
+	}{
+		{name: "sort", update: func(c *statsConfig) { c.sort = "p95" }, err: `unknown sort column "p95"`},
+		{name: "order", update: func(c *statsConfig) { c.order = "mtime" }, err: `unknown order "mtime"`},
+		{name: "time", update: func(c *statsConfig) { c.time = "total" }, err: `unknown time "total"`},
+		{name: "output format", update: func(c *statsConfig) { c.format = "yaml" }, err: `unknown output format "yaml"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			statsConf := testStatsConfig()
+			c.update(statsConf)
+
+			buffer := &bytes.Buffer{}
+			err := runStats(&config{inputFormat: parser.FormatAuto}, nil, statsConf, diffPaths, buffer)
+			assert.ErrorContains(t, err, c.err)
+			assert.Empty(t, buffer.String())
+		})
+	}
+}
+
+func TestStatsGauges(t *testing.T) {
+	stats := []*analysis.Stats{{
+		ID:    "out/one.js",
+		Name:  "one.js",
+		Count: 3,
+		Mean:  2 * time.Second,
+		P50:   time.Second,
+		P90:   3 * time.Second,
+		P99:   4 * time.Second,
+		Max:   5 * time.Second,
+		Trend: -500 * time.Millisecond,
+	}}
+
+	gauges := statsGauges(stats, analysis.TimeInclusive)
+	assert.Len(t, gauges, 7)
+
+	target := []attribute.KeyValue{
+		attribute.String("target.id", "out/one.js"),
+		attribute.String("target.name", "one.js"),
+		attribute.String("time", analysis.TimeInclusive),
+	}
+
+	assert.Equal(t, "makeotel.target.count", gauges[0].Name)
+	assert.Equal(t, target, gauges[0].Attributes)
+	assert.Equal(t, 3.0, gauges[0].Value)
+
+	// each statistic is its own gauge, told apart by an attribute
+	assert.Equal(t, "makeotel.target.duration", gauges[3].Name)
+	assert.Equal(t, append([]attribute.KeyValue{attribute.String("statistic", analysis.StatP90)}, target...), gauges[3].Attributes)
+	assert.Equal(t, 3.0, gauges[3].Value)
+
+	assert.Equal(t, "makeotel.target.trend", gauges[6].Name)
+	assert.Equal(t, target, gauges[6].Attributes)
+	assert.Equal(t, -0.5, gauges[6].Value)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"makeotel/tracing"
+	"os"
+
+	"github.com/spf13/pflag"
+)
+
+func traceParentCommand() *command {
+	traceParent := ""
+
+	return &command{
+		name:    "traceparent",
+		summary: "Print the trace parent for a target, for its recipe to pass on",
+		usage:   "makeotel traceparent [flags] <target>",
+		description: `Prints the W3C traceparent of the span which the target will get, for a recipe
+to give to the tools it runs, so that their spans are put under the target:
+
+  %.js: %.ts
+  	TRACEPARENT=$$(makeotel traceparent $@) tsc $<
+
+The target's span is worked out from the TRACEPARENT which makeotel exec gave
+make, or which the build was given to pass on to makeotel send.  With no
+TRACEPARENT, nothing is printed, so the recipe still works outside makeotel.`,
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			flags := pflag.NewFlagSet("traceparent", pflag.ContinueOnError)
+			flags.StringVar(&traceParent, "trace-parent", defaults.String("trace-parent", "", "TRACEPARENT"), "the traceparent the build ran under.  Can also be set by TRACEPARENT env var.")
+
+			return []flagGroup{
+				{"Trace Flags", flags},
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: func(conf *config, otelConf *tracing.Config, args []string) error {
+			return runTraceParent(traceParent, args, os.Stdout)
+		},
+	}
+}
+
+func runTraceParent(traceParent string, args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("traceparent takes one argument: target")
+	}
+
+	if traceParent == "" {
+		return nil
+	}
+
+	// a recipe shouldn't fail because of the tracing, so an invalid parent
+	// is only reported
+	target, err := tracing.TargetTraceParent(traceParent, args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "makeotel: ignoring invalid trace parent %q: %s\n", traceParent, err)
+		return nil
+	}
+
+	fmt.Fprintln(w, target)
+	return nil
+}
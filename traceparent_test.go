@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"makeotel/layout"
+	"makeotel/parser"
+	"makeotel/tracing"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const buildParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestRunTraceParent(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, runTraceParent(buildParent, []string{"one.js"}, buffer))
+
+	expected, err := tracing.TargetTraceParent(buildParent, "one.js")
+	assert.NoError(t, err)
+	assert.Equal(t, expected+"\n", buffer.String())
+
+	// outside makeotel, and with a bad parent, the recipe still runs
+	buffer.Reset()
+	assert.NoError(t, runTraceParent("", []string{"one.js"}, buffer))
+	assert.NoError(t, runTraceParent("00-abc", []string{"one.js"}, buffer))
+	assert.Empty(t, buffer.String())
+
+	assert.Error(t, runTraceParent(buildParent, nil, buffer))
+}
+
+// TestExportTargetSpanIDs checks that the span for each target's recipe has
+// the id which its recipe was given by `makeotel traceparent`.
+func TestExportTargetSpanIDs(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithIDGenerator(tracing.NewTargetIDGenerator()),
+	)
+
+	previous, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(propagator)
+	}()
+
+	profile, err := parseFile("example/callgrind.out.build-3", parser.FormatAuto)
+	assert.NoError(t, err)
+
+	conf := &config{traceParent: buildParent, bodySpanSuffix: "_body"}
+	exportProfiles(conf.traceContext(context.Background()), conf, []*profileFile{{profile: profile, start: time.Now()}})
+
+	ids := map[string]trace.SpanID{}
+	for _, span := range exporter.GetSpans() {
+		ids[span.Name] = span.SpanContext.SpanID()
+	}
+
+	recipes := 0
+	layout.Build(profile, time.Now(), conf.layoutOptions()).Walk(func(span *layout.Span, depth int) {
+		expected, err := tracing.TargetTraceParent(buildParent, span.ID)
+		assert.NoError(t, err)
+
+		if runsRecipe(span) {
+			recipes++
+			assert.Equal(t, strings.Split(expected, "-")[2], ids[span.Name].String(), span.Name)
+		} else {
+			assert.NotEqual(t, strings.Split(expected, "-")[2], ids[span.Name].String(), span.Name)
+		}
+	})
+	assert.Greater(t, recipes, 1)
+}
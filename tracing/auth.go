@@ -0,0 +1,128 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secret files should be tiny, anything bigger is probably the wrong path
+const maxSecretFileSize = 64 * 1024
+
+// readSecretFile reads a file containing credentials.  Files which other users
+// can write to are refused, as anyone could replace the credentials in them.
+func readSecretFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("expected %s to be a regular file", path)
+	}
+
+	if info.Mode().Perm()&0022 != 0 {
+		return "", fmt.Errorf("%s is writable by other users (mode %s), refusing to read credentials from it", path, info.Mode().Perm())
+	}
+
+	content, err := io.ReadAll(io.LimitReader(f, maxSecretFileSize+1))
+	if err != nil {
+		return "", err
+	}
+
+	if len(content) > maxSecretFileSize {
+		return "", fmt.Errorf("%s is larger than %d bytes, refusing to read credentials from it", path, maxSecretFileSize)
+	}
+
+	return string(bytes.TrimSpace(content)), nil
+}
+
+// parseHeadersFile reads one header per line, either as `key=value` or
+// `Key: value`.  Blank lines and lines starting with # are ignored.
+func parseHeadersFile(path string) (map[string]string, error) {
+	content, err := readSecretFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.IndexAny(line, "=:")
+		if i <= 0 {
+			// don't include the line, it is probably a secret
+			return nil, fmt.Errorf("%s:%d: expected a header in the form key=value or key: value", path, lineNumber)
+		}
+
+		headers[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+	}
+
+	return headers, nil
+}
+
+func basicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+var sensitiveHeaderParts = []string{"auth", "key", "token", "secret", "password", "cookie"}
+
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveHeaderParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact replaces every known credential in s, so that it can be written to
+// debug output.
+func (c *Config) Redact(s string) string {
+	for _, secret := range c.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// RedactedHeaders describes the headers which will be sent, with the values
+// of any credentials replaced.
+func (c *Config) RedactedHeaders() string {
+	names := make([]string, 0, len(c.Headers))
+	for name := range c.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+c.Redact(c.Headers[name]))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func (c *Config) addSecret(value string) {
+	if value != "" {
+		c.secrets = append(c.secrets, value)
+	}
+}
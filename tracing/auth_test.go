@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, content string, mode os.FileMode) string {
+	path := filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(path, []byte(content), mode))
+	assert.NoError(t, os.Chmod(path, mode))
+	return path
+}
+
+func TestParseHeaders(t *testing.T) {
+	conf := &Config{HeadersRaw: []string{"x-team=builds", "x-api-key=abc=="}}
+
+	assert.NoError(t, conf.ParseHeaders())
+	assert.Equal(t, map[string]string{"x-team": "builds", "x-api-key": "abc=="}, conf.Headers)
+	assert.Equal(t, "x-api-key=[REDACTED] x-team=builds", conf.RedactedHeaders())
+}
+
+func TestParseHeadersInvalid(t *testing.T) {
+	conf := &Config{HeadersRaw: []string{"x-team"}}
+	assert.Error(t, conf.ParseHeaders())
+}
+
+func TestParseHeadersFromFile(t *testing.T) {
+	path := writeFile(t, "# honeycomb\nx-honeycomb-team=abc123\n\nx-dataset: builds\n", 0600)
+
+	conf := &Config{
+		HeadersRaw:  []string{"x-dataset=overridden"},
+		HeadersFile: path,
+	}
+
+	assert.NoError(t, conf.ParseHeaders())
+	assert.Equal(t, map[string]string{"x-honeycomb-team": "abc123", "x-dataset": "builds"}, conf.Headers)
+	assert.Equal(t, "sent [REDACTED]", conf.Redact("sent abc123"))
+
+	// the file keeps credentials off the command line, so nothing from it
+	// is shown, whatever the header is called
+	assert.Equal(t, "x-dataset=[REDACTED] x-honeycomb-team=[REDACTED]", conf.RedactedHeaders())
+}
+
+func TestParseHeadersFileWritableByOthers(t *testing.T) {
+	path := writeFile(t, "x-honeycomb-team=abc123\n", 0666)
+
+	conf := &Config{HeadersFile: path}
+	assert.Error(t, conf.ParseHeaders())
+}
+
+func TestBearerToken(t *testing.T) {
+	path := writeFile(t, "s3cret\n", 0600)
+
+	conf := &Config{BearerTokenFile: path}
+
+	assert.NoError(t, conf.ParseHeaders())
+	assert.Equal(t, "Bearer s3cret", conf.Headers["Authorization"])
+	assert.Equal(t, "Authorization=Bearer [REDACTED]", conf.RedactedHeaders())
+}
+
+func TestBasicAuth(t *testing.T) {
+	path := writeFile(t, "hunter2", 0400)
+
+	conf := &Config{BasicAuthUser: "builds", BasicAuthPasswordFile: path}
+
+	assert.NoError(t, conf.ParseHeaders())
+	assert.Equal(t, "Basic YnVpbGRzOmh1bnRlcjI=", conf.Headers["Authorization"])
+	assert.Equal(t, "Authorization=Basic [REDACTED]", conf.RedactedHeaders())
+}
+
+func TestBasicAuthRequiresPassword(t *testing.T) {
+	conf := &Config{BasicAuthUser: "builds"}
+	assert.Error(t, conf.ParseHeaders())
+}
+
+func TestBearerAndBasicAuthConflict(t *testing.T) {
+	path := writeFile(t, "s3cret", 0600)
+
+	conf := &Config{BearerTokenFile: path, BasicAuthUser: "builds", BasicAuthPasswordFile: path}
+	assert.Error(t, conf.ParseHeaders())
+}
@@ -0,0 +1,25 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// baggageProcessor copies the baggage entries onto every span as attributes,
+// so that values such as CI job ids can be searched for in the backend.
+type baggageProcessor struct{}
+
+func (p baggageProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	for _, member := range baggage.FromContext(ctx).Members() {
+		s.SetAttributes(attribute.String(member.Key(), member.Value()))
+	}
+}
+
+func (p baggageProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+func (p baggageProcessor) Shutdown(ctx context.Context) error { return nil }
+
+func (p baggageProcessor) ForceFlush(ctx context.Context) error { return nil }
@@ -3,29 +3,29 @@ package tracing
 import (
 	"context"
 	"fmt"
-	"makeotel/version"
-	"net"
-	"net/url"
-	"regexp"
+	"os"
 	"strings"
+	"sync"
 
 	"github.com/go-logr/logr/funcr"
 	"go.opentelemetry.io/otel"
 	otlpgrpc "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	otlphttp "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
 )
 
 func InitTracer(conf *Config) (func(), error) {
 	ctx := context.Background()
 
+	// the diagnostics go to stderr, leaving stdout for the command's output,
+	// such as --output-format json
 	if conf.Debug {
 		otel.SetLogger(funcr.New(func(prefix, args string) {
-			fmt.Println(args)
+			fmt.Fprintln(os.Stderr, conf.Redact(args))
 		}, funcr.Options{Verbosity: 100}))
+
+		fmt.Fprintln(os.Stderr, "exporter headers:", conf.RedactedHeaders())
 	}
 
 	exporter, err := createExporter(ctx, conf)
@@ -33,141 +33,250 @@ func InitTracer(conf *Config) (func(), error) {
 		return nil, err
 	}
 
-	ssp := sdktrace.NewSimpleSpanProcessor(exporter)
+	res, err := createResource(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithIDGenerator(NewTargetIDGenerator()),
+		sdktrace.WithSpanProcessor(baggageProcessor{}),
+	}
+
+	// with no exporter, spans are still created (so the trace ids are valid)
+	// but go nowhere
+	if exporter != nil {
+		exporter = errorRecorder{exporter}
+		opts = append(opts, sdktrace.WithSpanProcessor(sdktrace.NewSimpleSpanProcessor(exporter)))
+	}
 
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithResource(
-			resource.NewWithAttributes(
-				semconv.SchemaURL,
-				semconv.ServiceNameKey.String("makefile"),
-				semconv.ServiceVersionKey.String(version.VersionNumber()),
-			)),
-		sdktrace.WithSpanProcessor(ssp),
-	)
+	tracerProvider := sdktrace.NewTracerProvider(opts...)
 
 	otel.SetTracerProvider(tracerProvider)
 
-	// set up the W3C trace context as the global propagator
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+	// set up the W3C trace context and baggage as the global propagators
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
 
 	return func() {
 		tracerProvider.Shutdown(ctx)
-		exporter.Shutdown(ctx)
+		if exporter != nil {
+			exporter.Shutdown(ctx)
+		}
 	}, nil
 }
 
+// errorRecorder keeps the errors from exporting spans, which the span
+// processor only passes to otel.Handle, so that they can be checked with
+// ExportError.
+type errorRecorder struct {
+	sdktrace.SpanExporter
+}
+
+var (
+	exportErrMu sync.Mutex
+	exportErr   error
+)
+
+func (e errorRecorder) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil {
+		exportErrMu.Lock()
+		exportErr = err
+		exportErrMu.Unlock()
+	}
+
+	return err
+}
+
+// ExportError returns the last error from exporting spans since it was last
+// called.  Spans are exported as they end, so it covers every span ended
+// before it was called.
+func ExportError() error {
+	exportErrMu.Lock()
+	defer exportErrMu.Unlock()
+
+	err := exportErr
+	exportErr = nil
+	return err
+}
+
+const (
+	ExporterOtlp    = "otlp"
+	ExporterConsole = "console"
+	ExporterNone    = "none"
+)
+
+const (
+	ProtocolGrpc = "grpc"
+	ProtocolHttp = "http/protobuf"
+)
+
 type Config struct {
+	Exporter   string
 	Endpoint   string
+	Protocol   string
+	Insecure   bool
 	HeadersRaw []string
 	Debug      bool
 
-	Headers map[string]string
-}
+	HeadersFile           string
+	BearerTokenFile       string
+	BasicAuthUser         string
+	BasicAuthPasswordFile string
 
-func (c *Config) ParseHeaders() error {
+	ServiceName           string
+	ResourceAttributesRaw []string
 
-	headers := map[string]string{}
+	Headers            map[string]string
+	ResourceAttributes map[string]string
 
-	for _, pair := range c.Headers {
-		s := strings.Split(pair, "=")
-		if len(pair) != 2 {
-			return fmt.Errorf("expected a key value pair in the form key=value, but got %s", pair)
-		}
+	secrets []string
+}
 
-		header := s[0]
-		value := s[1]
+func (c *Config) ParseResourceAttributes() error {
 
-		headers[header] = value
+	attributes, err := parsePairs(c.ResourceAttributesRaw)
+	if err != nil {
+		return err
 	}
 
-	c.Headers = headers
+	c.ResourceAttributes = attributes
 	return nil
 }
 
-func createExporter(ctx context.Context, conf *Config) (sdktrace.SpanExporter, error) {
+func parsePairs(pairs []string) (map[string]string, error) {
 
-	endpoint := strings.ToLower(conf.Endpoint)
-	u, err := url.Parse(endpoint)
-	if err != nil {
-		return nil, err
+	values := map[string]string{}
+
+	for _, pair := range pairs {
+		s := strings.SplitN(pair, "=", 2)
+		if len(s) != 2 || strings.TrimSpace(s[0]) == "" {
+			return nil, fmt.Errorf("expected a key value pair in the form key=value, but got %s", pair)
+		}
+
+		values[strings.TrimSpace(s[0])] = strings.TrimSpace(s[1])
 	}
 
-	if strings.HasPrefix(endpoint, "https://") || strings.HasPrefix(endpoint, "http://") {
+	return values, nil
+}
 
-		opts := []otlphttp.Option{}
+// ParseHeaders combines the --otlp-headers pairs with the headers and
+// credentials read from files.  Values read from files, and the values of
+// headers which look like credentials, are remembered so that they can be
+// redacted from debug output.  A vendor's key can be in a header with any
+// name, so every value in the headers file is treated as a credential.
+func (c *Config) ParseHeaders() error {
 
-		hostAndPort := u.Host
-		if u.Port() == "" {
-			if u.Scheme == "https" {
-				hostAndPort += ":443"
-			} else {
-				hostAndPort += ":80"
-			}
-		}
-		opts = append(opts, otlphttp.WithEndpoint(hostAndPort))
+	headers, err := parsePairs(c.HeadersRaw)
+	if err != nil {
+		return err
+	}
 
-		if u.Path == "" {
-			u.Path = "/v1/traces"
+	for name, value := range headers {
+		if isSensitiveHeader(name) {
+			c.addSecret(value)
 		}
-		opts = append(opts, otlphttp.WithURLPath(u.Path))
+	}
 
-		if u.Scheme == "http" {
-			opts = append(opts, otlphttp.WithInsecure())
+	if c.HeadersFile != "" {
+		fromFile, err := parseHeadersFile(c.HeadersFile)
+		if err != nil {
+			return err
 		}
 
-		opts = append(opts, otlphttp.WithHeaders(conf.Headers))
-
-		return otlphttp.New(ctx, opts...)
-	} else {
-		opts := []otlpgrpc.Option{}
+		for name, value := range fromFile {
+			headers[name] = value
+			c.addSecret(value)
+		}
+	}
 
-		opts = append(opts, otlpgrpc.WithEndpoint(endpoint))
+	if c.BearerTokenFile != "" && c.BasicAuthUser != "" {
+		return fmt.Errorf("only one of a bearer token or basic auth can be used")
+	}
 
-		isLocal, err := isLoopbackAddress(endpoint)
+	if c.BearerTokenFile != "" {
+		token, err := readSecretFile(c.BearerTokenFile)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		if isLocal {
-			opts = append(opts, otlpgrpc.WithInsecure())
+		headers["Authorization"] = "Bearer " + token
+		c.addSecret(token)
+	}
+
+	if c.BasicAuthUser != "" {
+		if c.BasicAuthPasswordFile == "" {
+			return fmt.Errorf("basic auth requires a password file")
 		}
 
-		opts = append(opts, otlpgrpc.WithHeaders(conf.Headers))
+		password, err := readSecretFile(c.BasicAuthPasswordFile)
+		if err != nil {
+			return err
+		}
 
-		return otlpgrpc.New(ctx, opts...)
+		auth := basicAuth(c.BasicAuthUser, password)
+		headers["Authorization"] = auth
+		c.addSecret(password)
+		c.addSecret(strings.TrimPrefix(auth, "Basic "))
 	}
 
+	c.Headers = headers
+	return nil
 }
 
-func isLoopbackAddress(endpoint string) (bool, error) {
-	hpRe := regexp.MustCompile(`^[\w.-]+:\d+$`)
-	uriRe := regexp.MustCompile(`^(http|https)`)
-
-	endpoint = strings.TrimSpace(endpoint)
+func createExporter(ctx context.Context, conf *Config) (sdktrace.SpanExporter, error) {
 
-	var hostname string
-	if hpRe.MatchString(endpoint) {
-		parts := strings.SplitN(endpoint, ":", 2)
-		hostname = parts[0]
-	} else if uriRe.MatchString(endpoint) {
-		u, err := url.Parse(endpoint)
-		if err != nil {
-			return false, err
-		}
-		hostname = u.Hostname()
+	switch conf.Exporter {
+	case ExporterOtlp, "":
+		return createOtlpExporter(ctx, conf)
+	case ExporterConsole:
+		return NewConsoleExporter(os.Stderr), nil
+	case ExporterNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown exporter %q, expected one of %s, %s, or %s", conf.Exporter, ExporterOtlp, ExporterConsole, ExporterNone)
 	}
+}
 
-	ips, err := net.LookupIP(hostname)
+func createOtlpExporter(ctx context.Context, conf *Config) (sdktrace.SpanExporter, error) {
+
+	endpoint, err := resolveEndpoint(conf)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	allAreLoopback := true
-	for _, ip := range ips {
-		if !ip.IsLoopback() {
-			allAreLoopback = false
+	if endpoint.socket != "" {
+		return createUnixExporter(ctx, endpoint, conf.Headers)
+	}
+
+	if endpoint.http {
+		opts := []otlphttp.Option{}
+
+		opts = append(opts, otlphttp.WithEndpoint(endpoint.host))
+		opts = append(opts, otlphttp.WithURLPath(endpoint.path))
+
+		if endpoint.insecure {
+			opts = append(opts, otlphttp.WithInsecure())
 		}
+
+		opts = append(opts, otlphttp.WithHeaders(conf.Headers))
+
+		return otlphttp.New(ctx, opts...)
 	}
 
-	return allAreLoopback, nil
+	opts := []otlpgrpc.Option{}
+
+	opts = append(opts, otlpgrpc.WithEndpoint(endpoint.host))
+
+	if endpoint.insecure {
+		opts = append(opts, otlpgrpc.WithInsecure())
+	}
+
+	opts = append(opts, otlpgrpc.WithHeaders(conf.Headers))
+
+	return otlpgrpc.New(ctx, opts...)
 }
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// consoleExporter collects every span it is given, and prints them as a tree
+// when it is shut down.  Spans are exported as they end, so children arrive
+// before their parents; buffering them all is the only way to print a tree.
+type consoleExporter struct {
+	w io.Writer
+
+	mu    sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func NewConsoleExporter(w io.Writer) *consoleExporter {
+	return &consoleExporter{
+		w:     w,
+		spans: []sdktrace.ReadOnlySpan{},
+	}
+}
+
+func (e *consoleExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *consoleExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	children := map[trace.SpanID][]sdktrace.ReadOnlySpan{}
+	known := map[trace.SpanID]bool{}
+
+	for _, span := range e.spans {
+		known[span.SpanContext().SpanID()] = true
+	}
+
+	roots := []sdktrace.ReadOnlySpan{}
+	for _, span := range e.spans {
+		parent := span.Parent().SpanID()
+		if span.Parent().IsValid() && known[parent] {
+			children[parent] = append(children[parent], span)
+		} else {
+			roots = append(roots, span)
+		}
+	}
+
+	sortByStart(roots)
+	for _, root := range roots {
+		fmt.Fprintf(e.w, "trace %s\n", root.SpanContext().TraceID())
+		if res := root.Resource(); res != nil && res.Len() > 0 {
+			fmt.Fprintf(e.w, "resource%s\n", formatAttributes(res.Attributes()))
+		}
+		e.printSpan(root, children, "", "")
+	}
+
+	e.spans = []sdktrace.ReadOnlySpan{}
+	return nil
+}
+
+func (e *consoleExporter) printSpan(span sdktrace.ReadOnlySpan, children map[trace.SpanID][]sdktrace.ReadOnlySpan, prefix string, childPrefix string) {
+	status := ""
+	if span.Status().Code == codes.Error {
+		status = " error: " + span.Status().Description
+	}
+
+	fmt.Fprintf(e.w, "%s%s (%s)%s%s\n", prefix, span.Name(), span.EndTime().Sub(span.StartTime()), formatAttributes(span.Attributes()), status)
+
+	kids := children[span.SpanContext().SpanID()]
+	sortByStart(kids)
+
+	for i, child := range kids {
+		if i == len(kids)-1 {
+			e.printSpan(child, children, childPrefix+"└── ", childPrefix+"    ")
+		} else {
+			e.printSpan(child, children, childPrefix+"├── ", childPrefix+"│   ")
+		}
+	}
+}
+
+func formatAttributes(attrs []attribute.KeyValue) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		if attr.Value.Type() == attribute.STRING {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", attr.Key, attr.Value.AsString()))
+		} else {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", attr.Key, attr.Value.Emit()))
+		}
+	}
+
+	return " [" + strings.Join(pairs, " ") + "]"
+}
+
+func sortByStart(spans []sdktrace.ReadOnlySpan) {
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].StartTime().Before(spans[j].StartTime())
+	})
+}
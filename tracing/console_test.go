@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestConsoleExporterPrintsTree(t *testing.T) {
+	out := &bytes.Buffer{}
+	exporter := NewConsoleExporter(out)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "test"))),
+	)
+	tr := provider.Tracer("test")
+
+	start := time.Unix(1000, 0)
+
+	ctx, root := tr.Start(context.Background(), "build", trace.WithTimestamp(start))
+	root.SetAttributes(attribute.Int("called", 0))
+
+	_, one := tr.Start(ctx, "one.js", trace.WithTimestamp(start))
+	one.End(trace.WithTimestamp(start.Add(3 * time.Second)))
+
+	_, two := tr.Start(ctx, "two.js", trace.WithTimestamp(start.Add(3*time.Second)))
+	two.End(trace.WithTimestamp(start.Add(4 * time.Second)))
+
+	root.End(trace.WithTimestamp(start.Add(4 * time.Second)))
+
+	assert.NoError(t, provider.Shutdown(context.Background()))
+
+	lines := strings.SplitN(out.String(), "\n", 3)
+	assert.Equal(t, "trace "+root.SpanContext().TraceID().String(), lines[0])
+	assert.Contains(t, lines[1], `service.name="test"`)
+
+	expected := "build (4s) [called=0]\n" +
+		"├── one.js (3s)\n" +
+		"└── two.js (1s)\n"
+
+	assert.Equal(t, expected, lines[2])
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// otlpEndpoint is where to send OTLP data, and how.  It is worked out from the
+// config in one place, so that spans and metrics go to the same collector.
+type otlpEndpoint struct {
+	// socket is the path of a unix socket, when the endpoint is one
+	socket string
+
+	// http is true for OTLP/HTTP, and false for gRPC
+	http bool
+
+	// host is the host and port to connect to, when it isn't a socket
+	host string
+
+	// path is where OTLP/HTTP traces are posted, such as /v1/traces
+	path string
+
+	insecure bool
+}
+
+// resolveEndpoint picks the transport for the endpoint.  http:// and
+// https:// urls are always OTLP/HTTP; anything else uses --otlp-protocol.
+// Unix sockets, loopback addresses and http:// urls never use TLS.
+func resolveEndpoint(conf *Config) (*otlpEndpoint, error) {
+	if conf.Protocol != "" && conf.Protocol != ProtocolGrpc && conf.Protocol != ProtocolHttp {
+		return nil, fmt.Errorf("unknown otlp protocol %q, expected %s or %s", conf.Protocol, ProtocolGrpc, ProtocolHttp)
+	}
+
+	// socket paths are case sensitive, so check for them before lowercasing
+	if isUnixSocket(conf.Endpoint) {
+		path, err := socketPath(conf.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		return &otlpEndpoint{socket: path, http: conf.Protocol == ProtocolHttp, path: "/v1/traces", insecure: true}, nil
+	}
+
+	endpoint := strings.ToLower(conf.Endpoint)
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(endpoint, "https://") || strings.HasPrefix(endpoint, "http://") {
+		host := u.Host
+		if u.Port() == "" {
+			if u.Scheme == "https" {
+				host += ":443"
+			} else {
+				host += ":80"
+			}
+		}
+
+		path := u.Path
+		if path == "" {
+			path = "/v1/traces"
+		}
+
+		return &otlpEndpoint{http: true, host: host, path: path, insecure: u.Scheme == "http" || conf.Insecure}, nil
+	}
+
+	return &otlpEndpoint{
+		http:     conf.Protocol == ProtocolHttp,
+		host:     endpoint,
+		path:     "/v1/traces",
+		insecure: conf.Insecure || isLoopbackAddress(endpoint),
+	}, nil
+}
+
+// isLoopbackAddress decides if an endpoint refers to this machine, purely from
+// its shape.  No DNS lookups are done, so a hostname which happens to resolve
+// to a loopback address is not considered local; only `localhost`, loopback IP
+// literals and unix sockets are.
+func isLoopbackAddress(endpoint string) bool {
+	endpoint = strings.ToLower(strings.TrimSpace(endpoint))
+
+	if strings.HasPrefix(endpoint, "unix:") {
+		return true
+	}
+
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		endpoint = endpoint[i+3:]
+	}
+
+	if i := strings.IndexAny(endpoint, "/?#"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+
+	if i := strings.LastIndex(endpoint, "@"); i >= 0 {
+		endpoint = endpoint[i+1:]
+	}
+
+	host := endpoint
+	if h, _, err := net.SplitHostPort(endpoint); err == nil {
+		host = h
+	}
+
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback()
+	}
+
+	return false
+}
@@ -0,0 +1,230 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// Gauge is a single value to send as a metric, such as a statistic about a
+// target's duration across many builds.  Gauges with the same name are sent
+// as the data points of one metric.
+type Gauge struct {
+	Name        string
+	Description string
+	Unit        string
+	Attributes  []attribute.KeyValue
+	Value       float64
+}
+
+// SendMetrics exports the gauges to wherever the exporter would send spans.
+// The metrics SDK isn't stable for this version of otel, so the OTLP request
+// is built and sent directly.
+func SendMetrics(ctx context.Context, conf *Config, gauges []Gauge, ts time.Time) error {
+	switch conf.Exporter {
+	case ExporterOtlp, "":
+	case ExporterConsole:
+		writeGauges(os.Stderr, gauges)
+		return nil
+	case ExporterNone:
+		return nil
+	default:
+		return fmt.Errorf("unknown exporter %q, expected one of %s, %s, or %s", conf.Exporter, ExporterOtlp, ExporterConsole, ExporterNone)
+	}
+
+	endpoint, err := resolveEndpoint(conf)
+	if err != nil {
+		return err
+	}
+
+	res, err := createResource(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	req := &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource:  &resourcepb.Resource{Attributes: keyValues(res.Attributes())},
+			SchemaUrl: res.SchemaURL(),
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: "makeotel"},
+				Metrics: metrics(gauges, ts),
+			}},
+		}},
+	}
+
+	return exportMetrics(ctx, endpoint, conf.Headers, req)
+}
+
+func metrics(gauges []Gauge, ts time.Time) []*metricspb.Metric {
+	byName := map[string]*metricspb.Metric{}
+	result := []*metricspb.Metric{}
+
+	for _, gauge := range gauges {
+		metric, found := byName[gauge.Name]
+		if !found {
+			metric = &metricspb.Metric{
+				Name:        gauge.Name,
+				Description: gauge.Description,
+				Unit:        gauge.Unit,
+				Data:        &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}},
+			}
+			byName[gauge.Name] = metric
+			result = append(result, metric)
+		}
+
+		data := metric.Data.(*metricspb.Metric_Gauge).Gauge
+		data.DataPoints = append(data.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:   keyValues(gauge.Attributes),
+			TimeUnixNano: uint64(ts.UnixNano()),
+			Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: gauge.Value},
+		})
+	}
+
+	return result
+}
+
+func keyValues(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	result := make([]*commonpb.KeyValue, 0, len(attrs))
+
+	for _, attr := range attrs {
+		value := &commonpb.AnyValue{}
+
+		switch attr.Value.Type() {
+		case attribute.BOOL:
+			value.Value = &commonpb.AnyValue_BoolValue{BoolValue: attr.Value.AsBool()}
+		case attribute.INT64:
+			value.Value = &commonpb.AnyValue_IntValue{IntValue: attr.Value.AsInt64()}
+		case attribute.FLOAT64:
+			value.Value = &commonpb.AnyValue_DoubleValue{DoubleValue: attr.Value.AsFloat64()}
+		default:
+			value.Value = &commonpb.AnyValue_StringValue{StringValue: attr.Value.Emit()}
+		}
+
+		result = append(result, &commonpb.KeyValue{Key: string(attr.Key), Value: value})
+	}
+
+	return result
+}
+
+// exportTimeout bounds sending the metrics, so that a collector which can't
+// be reached doesn't hang the command.  It matches the exporters' default for
+// spans.
+var exportTimeout = 10 * time.Second
+
+// exportMetrics sends the metrics to the endpoint which spans are sent to,
+// swapping the path for traces for the one for metrics.
+func exportMetrics(ctx context.Context, endpoint *otlpEndpoint, headers map[string]string, req *colmetricspb.ExportMetricsServiceRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	path := strings.TrimSuffix(strings.TrimSuffix(endpoint.path, "/"), "/v1/traces") + "/v1/metrics"
+
+	if endpoint.socket != "" {
+		if endpoint.http {
+			client := newUnixHttpClient(endpoint.socket, headers)
+			defer client.Stop(ctx)
+
+			// the host is ignored by the dialer, but is required to make a
+			// valid request
+			return postProtobuf(ctx, client.client, "http://localhost"+path, headers, req)
+		}
+
+		return exportMetricsGrpc(ctx, "unix://"+endpoint.socket, grpc.WithTransportCredentials(insecure.NewCredentials()), headers, req)
+	}
+
+	if endpoint.http {
+		scheme := "https"
+		if endpoint.insecure {
+			scheme = "http"
+		}
+
+		client := &http.Client{Timeout: exportTimeout}
+		return postProtobuf(ctx, client, scheme+"://"+endpoint.host+path, headers, req)
+	}
+
+	creds := grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	if endpoint.insecure {
+		creds = grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+
+	return exportMetricsGrpc(ctx, endpoint.host, creds, headers, req)
+}
+
+func exportMetricsGrpc(ctx context.Context, target string, creds grpc.DialOption, headers map[string]string, req *colmetricspb.ExportMetricsServiceRequest) error {
+	conn, err := grpc.DialContext(ctx, target, creds)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if len(headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(headers))
+	}
+
+	_, err = colmetricspb.NewMetricsServiceClient(conn).Export(ctx, req)
+	return err
+}
+
+// postProtobuf sends an OTLP/HTTP protobuf request.
+func postProtobuf(ctx context.Context, client *http.Client, target string, headers map[string]string, message proto.Message) error {
+	body, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("failed to send to %s: %s", req.URL, res.Status)
+	}
+
+	return nil
+}
+
+// writeGauges prints the gauges for the console exporter, one per line.
+func writeGauges(w io.Writer, gauges []Gauge) {
+	sorted := make([]Gauge, len(gauges))
+	copy(sorted, gauges)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	for _, gauge := range sorted {
+		fmt.Fprintf(w, "%s %g%s\n", gauge.Name, gauge.Value, formatAttributes(gauge.Attributes))
+	}
+}
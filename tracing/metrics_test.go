@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+type metricsCollector struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+
+	requests []*colmetricspb.ExportMetricsServiceRequest
+	headers  []string
+}
+
+func (c *metricsCollector) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	c.requests = append(c.requests, req)
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		c.headers = append(c.headers, md.Get("x-team")...)
+	}
+
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+var testGauges = []Gauge{
+	{Name: "build.duration", Unit: "s", Attributes: []attribute.KeyValue{attribute.String("statistic", "p50")}, Value: 1.5},
+	{Name: "build.duration", Unit: "s", Attributes: []attribute.KeyValue{attribute.String("statistic", "max")}, Value: 4},
+	{Name: "build.count", Value: 3},
+}
+
+func TestSendMetricsGrpc(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "otel.sock")
+
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+
+	col := &metricsCollector{}
+	server := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(server, col)
+
+	go server.Serve(listener)
+	defer server.Stop()
+
+	ts := time.Unix(1_600_000_000, 0)
+	err = SendMetrics(context.Background(), &Config{
+		Endpoint: "unix://" + socket,
+		Headers:  map[string]string{"x-team": "builds"},
+	}, testGauges, ts)
+	assert.NoError(t, err)
+
+	assert.Len(t, col.requests, 1)
+	assert.Equal(t, []string{"builds"}, col.headers)
+
+	// gauges with the same name are one metric
+	metrics := col.requests[0].ResourceMetrics[0].ScopeMetrics[0].Metrics
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, "build.duration", metrics[0].Name)
+
+	points := metrics[0].GetGauge().DataPoints
+	assert.Len(t, points, 2)
+	assert.Equal(t, 1.5, points[0].GetAsDouble())
+	assert.Equal(t, "p50", points[0].Attributes[0].Value.GetStringValue())
+	assert.Equal(t, uint64(ts.UnixNano()), points[0].TimeUnixNano)
+}
+
+func TestSendMetricsHttp(t *testing.T) {
+	col := &metricsCollector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+
+		body, _ := io.ReadAll(r.Body)
+		req := &colmetricspb.ExportMetricsServiceRequest{}
+		assert.NoError(t, proto.Unmarshal(body, req))
+
+		col.requests = append(col.requests, req)
+	}))
+	defer server.Close()
+
+	// the path for traces is swapped for the one for metrics
+	err := SendMetrics(context.Background(), &Config{Endpoint: server.URL + "/v1/traces"}, testGauges, time.Now())
+	assert.NoError(t, err)
+
+	assert.Len(t, col.requests, 1)
+	assert.Len(t, col.requests[0].ResourceMetrics[0].ScopeMetrics[0].Metrics, 2)
+}
+
+func TestSendMetricsTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	timeout := exportTimeout
+	exportTimeout = 50 * time.Millisecond
+	defer func() { exportTimeout = timeout }()
+
+	err := SendMetrics(context.Background(), &Config{Endpoint: server.URL}, testGauges, time.Now())
+	assert.Error(t, err)
+}
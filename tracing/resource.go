@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"makeotel/version"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+const DefaultServiceName = "makefile"
+
+// createResource builds the resource describing where the build ran.  Each
+// option overrides the ones before it, so the precedence is: the defaults,
+// then detected attributes, then OTEL_RESOURCE_ATTRIBUTES and
+// OTEL_SERVICE_NAME, then the --resource-attributes and --service-name flags.
+func createResource(ctx context.Context, conf *Config) (*resource.Resource, error) {
+
+	attrs := make([]attribute.KeyValue, 0, len(conf.ResourceAttributes)+1)
+	for k, v := range conf.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	if conf.ServiceName != "" {
+		attrs = append(attrs, semconv.ServiceNameKey.String(conf.ServiceName))
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(
+			semconv.ServiceNameKey.String(DefaultServiceName),
+			semconv.ServiceVersionKey.String(version.VersionNumber()),
+		),
+		resource.WithHost(),
+		resource.WithOS(),
+		// not resource.WithProcess(), as the command args can contain headers
+		// with api keys in them
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessExecutablePath(),
+		resource.WithProcessOwner(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithContainer(),
+		resource.WithFromEnv(),
+		resource.WithAttributes(attrs...),
+	)
+
+	// a detector failing (e.g. not being in a container) still gives a usable
+	// resource, just with fewer attributes
+	if err != nil && !errors.Is(err, resource.ErrPartialResource) {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+)
+
+func resourceValue(t *testing.T, conf *Config, key attribute.Key) string {
+	res, err := createResource(context.Background(), conf)
+	assert.NoError(t, err)
+
+	val, _ := res.Set().Value(key)
+	return val.AsString()
+}
+
+func TestResourceDefaults(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "")
+
+	conf := &Config{}
+
+	assert.Equal(t, DefaultServiceName, resourceValue(t, conf, semconv.ServiceNameKey))
+	assert.NotEmpty(t, resourceValue(t, conf, semconv.HostNameKey))
+	assert.NotEmpty(t, resourceValue(t, conf, semconv.OSTypeKey))
+}
+
+func TestResourceEnvironmentOverridesDefaults(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "from-env")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "repo=env-repo,team=builds")
+
+	conf := &Config{}
+
+	assert.Equal(t, "from-env", resourceValue(t, conf, semconv.ServiceNameKey))
+	assert.Equal(t, "env-repo", resourceValue(t, conf, "repo"))
+	assert.Equal(t, "builds", resourceValue(t, conf, "team"))
+}
+
+func TestResourceFlagsOverrideEnvironment(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "from-env")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "repo=env-repo,team=builds")
+
+	conf := &Config{
+		ServiceName:           "from-flag",
+		ResourceAttributesRaw: []string{"repo=flag-repo"},
+	}
+	assert.NoError(t, conf.ParseResourceAttributes())
+
+	assert.Equal(t, "from-flag", resourceValue(t, conf, semconv.ServiceNameKey))
+	assert.Equal(t, "flag-repo", resourceValue(t, conf, "repo"))
+	assert.Equal(t, "builds", resourceValue(t, conf, "team"))
+}
+
+func TestParseResourceAttributesInvalid(t *testing.T) {
+	conf := &Config{ResourceAttributesRaw: []string{"novalue"}}
+	assert.Error(t, conf.ParseResourceAttributes())
+}
@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The spans for make's targets are only created from the profile once make
+// has finished, but the tools run by a recipe need a span to parent theirs to
+// while it runs.  So a target's span gets an id worked out from the trace, the
+// span the build ran under, and the target's id, rather than a random one.  A
+// recipe can work out the same id with TargetTraceParent, from the
+// TRACEPARENT it was given and the name of its target.
+
+type targetKey struct{}
+
+type targetSpan struct {
+	build  trace.SpanID
+	target string
+}
+
+// WithTarget gives the span next started with ctx the id for the target, in a
+// build which ran under the span build.
+func WithTarget(ctx context.Context, build trace.SpanContext, target string) context.Context {
+	return context.WithValue(ctx, targetKey{}, targetSpan{build: build.SpanID(), target: target})
+}
+
+// TargetSpanID is the id of the target's span, in a build which ran under the
+// span build.
+func TargetSpanID(traceID trace.TraceID, build trace.SpanID, target string) trace.SpanID {
+	hash := sha256.New()
+	hash.Write(traceID[:])
+	hash.Write(build[:])
+	hash.Write([]byte(target))
+
+	id := trace.SpanID{}
+	copy(id[:], hash.Sum(nil))
+
+	// an id of all zeros is invalid
+	if !id.IsValid() {
+		id[len(id)-1] = 1
+	}
+
+	return id
+}
+
+// TargetTraceParent gives the traceparent for the target's span, from the
+// traceparent of the span the build ran under.
+func TargetTraceParent(parent string, target string) (string, error) {
+	if err := ValidateTraceParent(parent); err != nil {
+		return "", err
+	}
+
+	prop := propagation.TraceContext{}
+	carrier := NewCliCarrier()
+	carrier.Set("traceparent", parent)
+
+	build := trace.SpanContextFromContext(prop.Extract(context.Background(), carrier))
+	if !build.IsValid() {
+		return "", fmt.Errorf("invalid trace parent %q", parent)
+	}
+
+	sc := build.WithSpanID(TargetSpanID(build.TraceID(), build.SpanID(), target)).WithRemote(false)
+
+	carrier = NewCliCarrier()
+	prop.Inject(trace.ContextWithSpanContext(context.Background(), sc), carrier)
+
+	return carrier.Get("traceparent"), nil
+}
+
+// targetIDGenerator gives the spans marked by WithTarget their target's id,
+// and every other span a random one.
+type targetIDGenerator struct {
+	sync.Mutex
+	random *rand.Rand
+}
+
+func NewTargetIDGenerator() sdktrace.IDGenerator {
+	return &targetIDGenerator{random: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (g *targetIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.Lock()
+	defer g.Unlock()
+
+	traceID := trace.TraceID{}
+	for !traceID.IsValid() {
+		g.random.Read(traceID[:])
+	}
+
+	return traceID, g.randomSpanID()
+}
+
+func (g *targetIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	if target, ok := ctx.Value(targetKey{}).(targetSpan); ok {
+		return TargetSpanID(traceID, target.build, target.target)
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	return g.randomSpanID()
+}
+
+func (g *targetIDGenerator) randomSpanID() trace.SpanID {
+	spanID := trace.SpanID{}
+	for !spanID.IsValid() {
+		g.random.Read(spanID[:])
+	}
+	return spanID
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const buildParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestTargetTraceParent(t *testing.T) {
+	parent, err := TargetTraceParent(buildParent, "one.js")
+	assert.NoError(t, err)
+
+	// the same trace and flags, under another span
+	parts := strings.Split(parent, "-")
+	assert.Equal(t, []string{"00", "4bf92f3577b34da6a3ce929d0e0e4736"}, parts[:2])
+	assert.NotEqual(t, "00f067aa0ba902b7", parts[2])
+	assert.Equal(t, "01", parts[3])
+
+	again, err := TargetTraceParent(buildParent, "one.js")
+	assert.NoError(t, err)
+	assert.Equal(t, parent, again)
+
+	other, err := TargetTraceParent(buildParent, "two.js")
+	assert.NoError(t, err)
+	assert.NotEqual(t, parent, other)
+
+	_, err = TargetTraceParent("00-abc", "one.js")
+	assert.Error(t, err)
+}
+
+func TestTargetIDGenerator(t *testing.T) {
+	provider := sdktrace.NewTracerProvider(sdktrace.WithIDGenerator(NewTargetIDGenerator()))
+	tracer := provider.Tracer("test")
+
+	build := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": buildParent})
+	buildSpan := trace.SpanContextFromContext(build)
+
+	_, target := tracer.Start(WithTarget(build, buildSpan, "one.js"), "one.js")
+	_, other := tracer.Start(build, "other")
+
+	expected, err := TargetTraceParent(buildParent, "one.js")
+	assert.NoError(t, err)
+
+	assert.Equal(t, buildSpan.TraceID(), target.SpanContext().TraceID())
+	assert.Equal(t, strings.Split(expected, "-")[2], target.SpanContext().SpanID().String())
+	assert.NotEqual(t, target.SpanContext().SpanID(), other.SpanContext().SpanID())
+
+	// a new trace gets random ids
+	_, root := tracer.Start(context.Background(), "root")
+	assert.True(t, root.SpanContext().IsValid())
+}
@@ -2,25 +2,110 @@ package tracing
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 
 	"go.opentelemetry.io/otel"
 )
 
-func WithTraceParent(ctx context.Context, parent string) context.Context {
-	if parent == "" {
-		return ctx
+// ValidateTraceParent checks a traceparent against the W3C Trace Context
+// format, so that a bad value can be reported rather than silently starting
+// a new trace.  https://www.w3.org/TR/trace-context/#traceparent-header
+func ValidateTraceParent(parent string) error {
+	parts := strings.Split(parent, "-")
+	if len(parts) < 4 {
+		return fmt.Errorf("expected 4 fields in the form version-traceid-parentid-flags, but got %d", len(parts))
+	}
+
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	if !isHex(version, 2) {
+		return fmt.Errorf("version should be 2 lowercase hex characters, but got %q", version)
+	}
+
+	if version == "ff" {
+		return fmt.Errorf("version ff is invalid")
 	}
 
+	// later versions may add fields, but version 00 has exactly 4
+	if version == "00" && len(parts) != 4 {
+		return fmt.Errorf("version 00 should have 4 fields, but got %d", len(parts))
+	}
+
+	if !isHex(traceID, 32) {
+		return fmt.Errorf("trace id should be 32 lowercase hex characters, but got %q", traceID)
+	}
+
+	if strings.Trim(traceID, "0") == "" {
+		return fmt.Errorf("trace id cannot be all zeros")
+	}
+
+	if !isHex(parentID, 16) {
+		return fmt.Errorf("parent id should be 16 lowercase hex characters, but got %q", parentID)
+	}
+
+	if strings.Trim(parentID, "0") == "" {
+		return fmt.Errorf("parent id cannot be all zeros")
+	}
+
+	if !isHex(flags, 2) {
+		return fmt.Errorf("flags should be 2 lowercase hex characters, but got %q", flags)
+	}
+
+	return nil
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+
+	for _, c := range s {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithTraceContext extracts the W3C traceparent, tracestate and baggage
+// values into ctx.  The state is only used when there is a parent.
+func WithTraceContext(ctx context.Context, parent string, state string, bag string) context.Context {
+
 	// https://github.com/open-telemetry/opentelemetry-go/blob/main/propagation/trace_context.go#29
 	// the 'traceparent' key is a private constant in the otel library so this
 	// is using an internal detail but it's probably fine
 	carrier := NewCliCarrier()
-	carrier.Set("traceparent", parent)
+	if parent != "" {
+		carrier.Set("traceparent", parent)
+	}
+	if state != "" {
+		carrier.Set("tracestate", state)
+	}
+	if bag != "" {
+		carrier.Set("baggage", bag)
+	}
+
+	if len(carrier.data) == 0 {
+		return ctx
+	}
 
 	prop := otel.GetTextMapPropagator()
 	return prop.Extract(ctx, carrier)
 }
 
+// Environ gives the propagation headers for the span in ctx as environment
+// variables (TRACEPARENT, TRACESTATE, BAGGAGE), so that child processes can parent
+// their spans to it.
+func Environ(ctx context.Context) []string {
+	carrier := NewCliCarrier()
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	return carrier.Environ()
+}
+
 type CliCarrier struct {
 	data map[string]string
 }
@@ -51,3 +136,15 @@ func (c *CliCarrier) Get(key string) string {
 func (c *CliCarrier) Set(key string, value string) {
 	c.data[key] = value
 }
+
+// Environ converts the carrier's keys to environment variables, following
+// the convention of TRACEPARENT for the traceparent key.
+func (c *CliCarrier) Environ() []string {
+	env := make([]string, 0, len(c.data))
+	for k, v := range c.data {
+		env = append(env, strings.ToUpper(k)+"="+v)
+	}
+	sort.Strings(env)
+
+	return env
+}
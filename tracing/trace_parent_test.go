@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func usePropagators() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+func TestEnvironRoundTrip(t *testing.T) {
+	usePropagators()
+
+	ctx := WithTraceContext(context.Background(), parent, "vendor=abc", "ci.job=42")
+
+	assert.Equal(t, []string{
+		"BAGGAGE=ci.job=42",
+		"TRACEPARENT=" + parent,
+		"TRACESTATE=vendor=abc",
+	}, Environ(ctx))
+}
+
+func TestEnvironWithoutSpan(t *testing.T) {
+	usePropagators()
+
+	assert.Empty(t, Environ(context.Background()))
+}
+
+func TestBaggageIsCopiedToSpans(t *testing.T) {
+	usePropagators()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(baggageProcessor{}),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+
+	ctx := WithTraceContext(context.Background(), parent, "", "ci.job=42,ci.branch=main")
+
+	ctx, root := provider.Tracer("test").Start(ctx, "build")
+	_, child := provider.Tracer("test").Start(ctx, "one.js")
+	child.End()
+	root.End()
+
+	for _, span := range recorder.Ended() {
+		attrs := map[string]string{}
+		for _, attr := range span.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.AsString()
+		}
+
+		assert.Equal(t, "42", attrs["ci.job"], span.Name())
+		assert.Equal(t, "main", attrs["ci.branch"], span.Name())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	}
+
+	assert.Len(t, recorder.Ended(), 2)
+}
+
+func TestValidateTraceParent(t *testing.T) {
+	valid := []string{
+		parent,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future",
+	}
+
+	for _, value := range valid {
+		assert.NoError(t, ValidateTraceParent(value), value)
+	}
+
+	invalid := []string{
+		"",
+		"4bf92f3577b34da6a3ce929d0e0e4736",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"0-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	}
+
+	for _, value := range invalid {
+		assert.Error(t, ValidateTraceParent(value), value)
+	}
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	otlpgrpc "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func isUnixSocket(endpoint string) bool {
+	return strings.HasPrefix(strings.ToLower(endpoint), "unix:")
+}
+
+// socketPath extracts the filesystem path from either `unix:///abs/path` or
+// `unix:relative/path` style endpoints.
+func socketPath(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+
+	if path == "" {
+		return "", fmt.Errorf("expected a socket path in the form unix:///path/to/socket, but got %s", endpoint)
+	}
+
+	return path, nil
+}
+
+func createUnixExporter(ctx context.Context, endpoint *otlpEndpoint, headers map[string]string) (sdktrace.SpanExporter, error) {
+
+	if endpoint.http {
+		return otlptrace.New(ctx, newUnixHttpClient(endpoint.socket, headers))
+	}
+
+	// grpc understands unix:// targets natively, and a socket never needs TLS
+	return otlpgrpc.New(ctx,
+		otlpgrpc.WithEndpoint("unix://"+endpoint.socket),
+		otlpgrpc.WithInsecure(),
+		otlpgrpc.WithHeaders(headers),
+	)
+}
+
+// unixHttpClient sends OTLP/HTTP protobuf requests over a unix socket.  The
+// otlptracehttp exporter doesn't allow its http.Client to be replaced, so
+// this is the minimal client needed to post traces to a collector sidecar.
+type unixHttpClient struct {
+	headers map[string]string
+	client  *http.Client
+}
+
+func newUnixHttpClient(path string, headers map[string]string) *unixHttpClient {
+	dialer := &net.Dialer{}
+
+	return &unixHttpClient{
+		headers: headers,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+func (c *unixHttpClient) Start(ctx context.Context) error {
+	return nil
+}
+
+func (c *unixHttpClient) Stop(ctx context.Context) error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *unixHttpClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	// the host is ignored by the dialer, but is required to make a valid request
+	return postProtobuf(ctx, c.client, "http://localhost/v1/traces", c.headers, &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: protoSpans,
+	})
+}
@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+type collector struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	mu      sync.Mutex
+	names   []string
+	headers []string
+}
+
+func (c *collector) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	c.record(req)
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		c.mu.Lock()
+		c.headers = append(c.headers, md.Get("x-team")...)
+		c.mu.Unlock()
+	}
+
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func (c *collector) record(req *coltracepb.ExportTraceServiceRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				c.names = append(c.names, span.Name)
+			}
+		}
+	}
+}
+
+func exportSpan(t *testing.T, conf *Config) {
+	ctx := context.Background()
+
+	exporter, err := createExporter(ctx, conf)
+	assert.NoError(t, err)
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	_, span := provider.Tracer("test").Start(ctx, "build")
+	span.End()
+
+	assert.NoError(t, provider.Shutdown(ctx))
+}
+
+func TestUnixSocketGrpc(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "otel.sock")
+
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+
+	col := &collector{}
+	server := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(server, col)
+
+	go server.Serve(listener)
+	defer server.Stop()
+
+	exportSpan(t, &Config{
+		Endpoint: "unix://" + socket,
+		Headers:  map[string]string{"x-team": "builds"},
+	})
+
+	assert.Equal(t, []string{"build"}, col.names)
+	assert.Equal(t, []string{"builds"}, col.headers)
+}
+
+func TestUnixSocketHttp(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "otel.sock")
+
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+
+	col := &collector{}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/traces", r.URL.Path)
+			assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+
+			body, _ := io.ReadAll(r.Body)
+			req := &coltracepb.ExportTraceServiceRequest{}
+			assert.NoError(t, proto.Unmarshal(body, req))
+
+			col.record(req)
+			col.headers = append(col.headers, r.Header.Get("x-team"))
+		}),
+	}
+
+	go server.Serve(listener)
+	defer server.Close()
+
+	exportSpan(t, &Config{
+		Endpoint: "unix://" + socket,
+		Protocol: ProtocolHttp,
+		Headers:  map[string]string{"x-team": "builds"},
+	})
+
+	assert.Equal(t, []string{"build"}, col.names)
+	assert.Equal(t, []string{"builds"}, col.headers)
+}
+
+func TestSocketPath(t *testing.T) {
+	path, err := socketPath("unix:///var/run/Otel.sock")
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/run/Otel.sock", path)
+
+	path, err = socketPath("unix:otel.sock")
+	assert.NoError(t, err)
+	assert.Equal(t, "otel.sock", path)
+
+	_, err = socketPath("unix://")
+	assert.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"makeotel/parser"
+	"makeotel/tracing"
+	"os"
+)
+
+func validateCommand() *command {
+	return &command{
+		name:        "validate",
+		summary:     "Check that profiles can be parsed",
+		usage:       "makeotel validate [flags] <path_to_profile>...",
+		description: "Parses each profile, and reports any which are invalid, without sending anything.",
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			input := inputFlags(defaults, conf)
+			input.MarkHidden("single-trace")
+			input.MarkHidden("attach")
+
+			return []flagGroup{
+				{"Input Flags", input},
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: runValidate,
+	}
+}
+
+func runValidate(conf *config, otelConf *tracing.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("validate takes at least one argument: path")
+	}
+
+	if err := parser.CheckFormat(conf.inputFormat); err != nil {
+		return err
+	}
+
+	paths, err := expandPaths(args)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, file := range paths {
+		profile, err := parseFile(file, conf.inputFormat)
+		if err == nil && len(profile.Roots()) == 0 {
+			err = fmt.Errorf("no root target found")
+		}
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("%s: ok, %d targets, %s\n", file, len(profile.Functions()), profile.TotalCost)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profiles are invalid", failed, len(paths))
+	}
+
+	return nil
+}
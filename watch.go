@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"makeotel/parser"
+	"makeotel/tracing"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type watchConfig struct {
+	pattern      string
+	processedDir string
+	failedDir    string
+	delete       bool
+	settle       time.Duration
+}
+
+func watchFlags(defaults settings, conf *watchConfig) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("watch", pflag.ContinueOnError)
+	flags.StringVar(&conf.pattern, "pattern", defaults.String("pattern", "callgrind.out.*"), "the file names to watch for")
+	flags.StringVar(&conf.processedDir, "processed-dir", defaults.String("processed-dir", "processed"), "where to move profiles once they are sent, relative to the watched directory")
+	flags.StringVar(&conf.failedDir, "failed-dir", defaults.String("failed-dir", "failed"), "where to move profiles which could not be parsed, relative to the watched directory")
+	flags.BoolVar(&conf.delete, "delete", defaults.Bool("delete", false), "delete profiles once they are sent, rather than moving them to --processed-dir")
+	flags.DurationVar(&conf.settle, "settle", defaults.Duration("settle", 2*time.Second), "how long a file must be unmodified before it is considered completely written")
+
+	return flags
+}
+
+func watchCommand() *command {
+	watchConf := &watchConfig{}
+
+	return &command{
+		name:    "watch",
+		summary: "Send profiles as they are written to a directory",
+		usage:   "makeotel watch [flags] <directory>",
+		description: `Watches a directory for profiles written by Remake, and sends each one as it is
+completed.  Sent profiles are moved to --processed-dir (or deleted with
+--delete), so profiles left in the directory when makeotel was stopped are sent
+when it starts again.
+
+The start of each trace is worked out from when the profile was written, rather
+than --timestamp.`,
+
+		flags: func(defaults settings, conf *config, otelConf *tracing.Config) []flagGroup {
+			trace := traceFlags(defaults, conf)
+			trace.MarkHidden("timestamp")
+
+			input := inputFlags(defaults, conf)
+			input.MarkHidden("single-trace")
+			input.MarkHidden("attach")
+
+			return []flagGroup{
+				{"Watch Flags", watchFlags(defaults, watchConf)},
+				{"Input Flags", input},
+				{"Trace Flags", trace},
+				{"Output Flags", outputFlags(defaults, "watch", &conf.output)},
+				{"OpenTelemetry Flags", otelFlags(defaults, otelConf)},
+				{"General Flags", commandFlags(conf)},
+			}
+		},
+		run: func(conf *config, otelConf *tracing.Config, args []string) error {
+			return runWatch(conf, otelConf, watchConf, args)
+		},
+	}
+}
+
+func runWatch(conf *config, otelConf *tracing.Config, watchConf *watchConfig, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("watch takes one argument: directory")
+	}
+
+	if err := conf.checkTraceParent(); err != nil {
+		return err
+	}
+
+	if err := parser.CheckFormat(conf.inputFormat); err != nil {
+		return err
+	}
+
+	dir := args[0]
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	if watchConf.settle <= 0 {
+		return fmt.Errorf("--settle must be more than 0, but got %s", watchConf.settle)
+	}
+
+	if _, err := filepath.Match(watchConf.pattern, ""); err != nil {
+		return fmt.Errorf("invalid --pattern: %w", err)
+	}
+
+	if err := otelConf.ParseHeaders(); err != nil {
+		return err
+	}
+
+	if err := otelConf.ParseResourceAttributes(); err != nil {
+		return err
+	}
+
+	out, err := newReporter(&conf.output)
+	if err != nil {
+		return err
+	}
+	defer out.close()
+
+	shutdown, err := tracing.InitTracer(otelConf)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	events, err := watchDir(ctx, dir, watchConf.settle)
+	if err != nil {
+		return err
+	}
+
+	w := &watcher{
+		ctx:     conf.traceContext(context.Background()),
+		conf:    conf,
+		out:     out,
+		watch:   watchConf,
+		dir:     dir,
+		pending: map[string]bool{},
+	}
+
+	w.addExisting()
+
+	fmt.Fprintf(os.Stderr, "watching %s for %s\n", dir, watchConf.pattern)
+
+	ticker := time.NewTicker(watchConf.settle / 2)
+	defer ticker.Stop()
+
+	for {
+		w.processPending()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case path, ok := <-events:
+			if !ok {
+				// the events stop when we are interrupted, too
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("stopped watching %s", dir)
+			}
+			if matched, _ := filepath.Match(watchConf.pattern, filepath.Base(path)); matched {
+				w.pending[path] = true
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+type watcher struct {
+	ctx   context.Context
+	conf  *config
+	out   *reporter
+	watch *watchConfig
+	dir   string
+
+	// files which have been seen, but not yet sent
+	pending map[string]bool
+}
+
+// addExisting queues the files already in the directory, which were written
+// while we weren't running.
+func (w *watcher) addExisting() {
+	existing, _ := filepath.Glob(filepath.Join(w.dir, w.watch.pattern))
+	for _, path := range existing {
+		w.pending[path] = true
+	}
+}
+
+func (w *watcher) processPending() {
+	paths := make([]string, 0, len(w.pending))
+	for path := range w.pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if w.process(path) {
+			delete(w.pending, path)
+		}
+	}
+}
+
+// process sends the file if it has been completely written, returning true
+// once there is nothing more to do with it.
+func (w *watcher) process(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		// moved or deleted by something else
+		return true
+	}
+
+	// remake might still be writing it
+	if time.Since(info.ModTime()) < w.watch.settle {
+		return false
+	}
+
+	profile, err := parseFile(path, w.conf.inputFormat)
+	if err == nil && len(profile.Roots()) == 0 {
+		err = fmt.Errorf("no root target found")
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		w.moveTo(path, w.watch.failedDir)
+		return true
+	}
+
+	// forget the errors from sending earlier files
+	tracing.ExportError()
+
+	sent := exportProfiles(w.ctx, w.conf, []*profileFile{{
+		path:    path,
+		profile: profile,
+		start:   info.ModTime().Add(-profile.TotalCost),
+	}})
+
+	// the collector might be down, so keep the file to try again
+	if err := tracing.ExportError(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: not sent, will retry: %s\n", path, err)
+		return false
+	}
+
+	if err := w.out.report(path, profile.Roots()[0].Name, sent[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+	}
+
+	if w.watch.delete {
+		if err := os.Remove(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		}
+	} else {
+		w.moveTo(path, w.watch.processedDir)
+	}
+
+	return true
+}
+
+func (w *watcher) moveTo(path string, dir string) {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(w.dir, dir)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		return
+	}
+
+	if err := os.Rename(path, filepath.Join(dir, filepath.Base(path))); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+	}
+}
@@ -0,0 +1,71 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchDir uses inotify to report files in dir which have been closed after
+// writing, or moved into it.
+func watchDir(ctx context.Context, dir string, interval time.Duration) (<-chan string, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := unix.InotifyAddWatch(fd, dir, unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// wrapping the fd in a file lets the runtime poller do the blocking, and
+	// closing the file interrupts a pending read
+	f := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	events := make(chan string)
+
+	go func() {
+		defer close(events)
+
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				start := offset + unix.SizeofInotifyEvent
+				end := start + int(event.Len)
+
+				name := string(bytes.TrimRight(buf[start:end], "\x00"))
+				offset = end
+
+				if name == "" || event.Mask&unix.IN_ISDIR != 0 {
+					continue
+				}
+
+				select {
+				case events <- filepath.Join(dir, name):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,54 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// watchDir polls dir, reporting every file in it each interval; files which
+// are already being processed are ignored by the caller.
+func watchDir(ctx context.Context, dir string, interval time.Duration) (<-chan string, error) {
+	if _, err := os.ReadDir(dir); err != nil {
+		return nil, err
+	}
+
+	events := make(chan string)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+
+			for _, entry := range entries {
+				if entry.IsDir() {
+					continue
+				}
+
+				select {
+				case events <- filepath.Join(dir, entry.Name()):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
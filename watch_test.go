@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"makeotel/parser"
+	"makeotel/tracing"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+)
+
+func testWatcher(t *testing.T) (*watcher, *bytes.Buffer) {
+	out, err := newReporter(&outputConfig{format: OutputText})
+	assert.NoError(t, err)
+
+	buffer := &bytes.Buffer{}
+	out.w = buffer
+
+	return &watcher{
+		ctx:  context.Background(),
+		conf: &config{inputFormat: parser.FormatAuto},
+		out:  out,
+		watch: &watchConfig{
+			pattern:      "callgrind.out.*",
+			processedDir: "processed",
+			failedDir:    "failed",
+			settle:       time.Minute,
+		},
+		dir:     t.TempDir(),
+		pending: map[string]bool{},
+	}, buffer
+}
+
+func TestWatcherWaitsForFileToSettle(t *testing.T) {
+	w, buffer := testWatcher(t)
+	path := copyProfile(t, w.dir, "example/callgrind.out.build-1", "callgrind.out.1", time.Now())
+
+	assert.False(t, w.process(path))
+	assert.FileExists(t, path)
+	assert.Empty(t, buffer.String())
+}
+
+func TestWatcherMovesSentFile(t *testing.T) {
+	w, buffer := testWatcher(t)
+	path := copyProfile(t, w.dir, "example/callgrind.out.build-1", "callgrind.out.1", time.Now().Add(-time.Hour))
+
+	assert.True(t, w.process(path))
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, filepath.Join(w.dir, "processed", "callgrind.out.1"))
+	assert.Contains(t, buffer.String(), path)
+}
+
+func TestWatcherMovesBadFile(t *testing.T) {
+	w, buffer := testWatcher(t)
+	path := copyProfile(t, w.dir, "example/makefile", "callgrind.out.bad", time.Now().Add(-time.Hour))
+
+	assert.True(t, w.process(path))
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, filepath.Join(w.dir, "failed", "callgrind.out.bad"))
+	assert.Empty(t, buffer.String())
+}
+
+func TestWatcherSendsExistingFiles(t *testing.T) {
+	w, buffer := testWatcher(t)
+	old := time.Now().Add(-time.Hour)
+	first := copyProfile(t, w.dir, "example/callgrind.out.build-1", "callgrind.out.1", old)
+	second := copyProfile(t, w.dir, "example/callgrind.out.build-2", "callgrind.out.2", old)
+	copyProfile(t, w.dir, "example/makefile", "makefile", old)
+
+	w.addExisting()
+	assert.Equal(t, map[string]bool{first: true, second: true}, w.pending)
+
+	w.processPending()
+	assert.Empty(t, w.pending)
+	assert.FileExists(t, filepath.Join(w.dir, "processed", "callgrind.out.1"))
+	assert.FileExists(t, filepath.Join(w.dir, "processed", "callgrind.out.2"))
+	assert.FileExists(t, filepath.Join(w.dir, "makefile"))
+	assert.Contains(t, buffer.String(), first)
+	assert.Contains(t, buffer.String(), second)
+
+	_, err := os.Stat(filepath.Join(w.dir, "failed"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWatchRejectsNoSettle(t *testing.T) {
+	w, _ := testWatcher(t)
+	w.watch.settle = 0
+
+	err := runWatch(w.conf, &tracing.Config{Exporter: tracing.ExporterNone}, w.watch, []string{w.dir})
+	assert.ErrorContains(t, err, "--settle")
+}
+
+func TestWatcherKeepsFileWhenSendFails(t *testing.T) {
+	previous, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	defer func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(propagator)
+	}()
+
+	// nothing is listening on the socket
+	shutdown, err := tracing.InitTracer(&tracing.Config{
+		Endpoint: "unix://" + filepath.Join(t.TempDir(), "collector.sock"),
+		Protocol: tracing.ProtocolHttp,
+	})
+	assert.NoError(t, err)
+	defer shutdown()
+
+	w, buffer := testWatcher(t)
+	path := copyProfile(t, w.dir, "example/callgrind.out.build-1", "callgrind.out.1", time.Now().Add(-time.Hour))
+
+	assert.False(t, w.process(path))
+	assert.FileExists(t, path)
+	assert.NoFileExists(t, filepath.Join(w.dir, "processed", "callgrind.out.1"))
+	assert.Empty(t, buffer.String())
+}